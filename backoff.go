@@ -0,0 +1,161 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff computes how long to wait before next retry
+//
+// Implementations in this package are thread-safe.
+type Backoff interface {
+	// Next returns the duration to wait before next retry
+	Next() time.Duration
+	// Reset restores initial state, it's called after a successful run
+	Reset()
+}
+
+type constantBackoff time.Duration
+
+func (b constantBackoff) Next() time.Duration { return time.Duration(b) }
+func (b constantBackoff) Reset()              {}
+
+// ConstantBackoff creates a Backoff that always waits dur
+func ConstantBackoff(dur time.Duration) Backoff {
+	return constantBackoff(dur)
+}
+
+type exponentialBackoff struct {
+	lock    sync.Mutex
+	initial time.Duration
+	factor  float64
+	cur     time.Duration
+}
+
+func (b *exponentialBackoff) Next() (ret time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	ret = b.cur
+	if next := time.Duration(float64(b.cur) * b.factor); next > b.cur {
+		b.cur = next
+	}
+	return
+}
+
+func (b *exponentialBackoff) Reset() {
+	b.lock.Lock()
+	b.cur = b.initial
+	b.lock.Unlock()
+}
+
+// ExponentialBackoff creates a Backoff that waits initial at first, and
+// multiplies it by factor each time
+//
+// It stops growing when overflowed, so you might want to wrap it with
+// CappedBackoff.
+func ExponentialBackoff(initial time.Duration, factor float64) Backoff {
+	return &exponentialBackoff{
+		initial: initial,
+		factor:  factor,
+		cur:     initial,
+	}
+}
+
+type cappedBackoff struct {
+	max time.Duration
+	Backoff
+}
+
+func (b *cappedBackoff) Next() (ret time.Duration) {
+	if ret = b.Backoff.Next(); ret > b.max {
+		ret = b.max
+	}
+	return
+}
+
+// CappedBackoff creates a Backoff that never waits longer than max
+func CappedBackoff(max time.Duration, b Backoff) Backoff {
+	return &cappedBackoff{max: max, Backoff: b}
+}
+
+// lockedRand is a thread-safe random source
+type lockedRand struct {
+	lock sync.Mutex
+	r    *rand.Rand
+}
+
+func newLockedRand() *lockedRand {
+	return &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Int63n returns a random number in [0, n), or 0 if n <= 0
+func (r *lockedRand) Int63n(n int64) (ret int64) {
+	if n <= 0 {
+		return
+	}
+	r.lock.Lock()
+	ret = r.r.Int63n(n)
+	r.lock.Unlock()
+	return
+}
+
+type fullJitterBackoff struct {
+	rand *lockedRand
+	Backoff
+}
+
+func (b *fullJitterBackoff) Next() time.Duration {
+	return time.Duration(b.rand.Int63n(int64(b.Backoff.Next()) + 1))
+}
+
+// FullJitterBackoff creates a Backoff that waits random duration between 0 and
+// the duration computed by b
+func FullJitterBackoff(b Backoff) Backoff {
+	return &fullJitterBackoff{rand: newLockedRand(), Backoff: b}
+}
+
+type decorrelatedJitterBackoff struct {
+	lock sync.Mutex
+	rand *lockedRand
+	base time.Duration
+	max  time.Duration
+	cur  time.Duration
+}
+
+func (b *decorrelatedJitterBackoff) Next() time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	upper := b.cur * 3
+	if upper < b.cur || upper > b.max {
+		upper = b.max
+	}
+	b.cur = b.base + time.Duration(b.rand.Int63n(int64(upper-b.base)+1))
+	return b.cur
+}
+
+func (b *decorrelatedJitterBackoff) Reset() {
+	b.lock.Lock()
+	b.cur = b.base
+	b.lock.Unlock()
+}
+
+// DecorrelatedJitterBackoff creates a Backoff that waits random duration
+// between base and 3 times of previous duration, but no longer than max
+func DecorrelatedJitterBackoff(base, max time.Duration) Backoff {
+	if max < base {
+		max = base
+	}
+	return &decorrelatedJitterBackoff{
+		rand: newLockedRand(),
+		base: base,
+		max:  max,
+		cur:  base,
+	}
+}
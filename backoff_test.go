@@ -0,0 +1,99 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	b := CappedBackoff(5*time.Second, ExponentialBackoff(time.Second, 2))
+	expect := []time.Duration{1, 2, 4, 5, 5}
+	for idx, v := range expect {
+		if d := b.Next(); d != v*time.Second {
+			t.Fatalf("expected #%d to be %ds, got %v", idx, v, d)
+		}
+	}
+
+	b.Reset()
+	if d := b.Next(); d != time.Second {
+		t.Fatal("expected reset to 1s, got", d)
+	}
+}
+
+func TestJitterBackoff(t *testing.T) {
+	full := FullJitterBackoff(ConstantBackoff(time.Second))
+	dec := DecorrelatedJitterBackoff(time.Second, 10*time.Second)
+	for i := 0; i < 100; i++ {
+		if d := full.Next(); d < 0 || d > time.Second {
+			t.Fatal("full jitter out of range:", d)
+		}
+		if d := dec.Next(); d < time.Second || d > 10*time.Second {
+			t.Fatal("decorrelated jitter out of range:", d)
+		}
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	e := errors.New("")
+	f := Counter(func(n uint64) error {
+		if n < 3 {
+			return e
+		}
+		return nil
+	})
+
+	r := RetryWithBackoff(f, ConstantBackoff(10*time.Millisecond))
+	dur := cost(func() {
+		if err := r.Run(); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	})
+	if dur < 30*time.Millisecond {
+		t.Fatal("expected to wait at least 30ms, got", dur)
+	}
+}
+
+func TestRetryWithBackoffCancel(t *testing.T) {
+	f := CTXRunner(func(c context.Context) error {
+		return errors.New("")
+	})
+
+	r := RetryWithBackoff(f, ConstantBackoff(time.Hour))
+	go func() { time.Sleep(10 * time.Millisecond); r.Cancel() }()
+	dur := cost(func() {
+		if err := r.Run(); err != context.Canceled {
+			t.Fatal("unexpected error:", err)
+		}
+	})
+	if dur > time.Second {
+		t.Fatal("expected to be interrupted, got", dur)
+	}
+}
+
+func TestTryAtMostWithBackoff(t *testing.T) {
+	e := errors.New("")
+	ch := make(chan bool, 10)
+	f := CTXRunner(func(c context.Context) error {
+		ch <- true
+		return e
+	})
+
+	r := TryAtMostWithBackoff(3, f, ConstantBackoff(10*time.Millisecond))
+	dur := cost(func() {
+		if err := r.Run(); err != e {
+			t.Fatal("unexpected error:", err)
+		}
+	})
+	if l := len(ch); l != 3 {
+		t.Fatalf("expected 3 items, got %d", l)
+	}
+	if dur < 20*time.Millisecond || dur >= 200*time.Millisecond {
+		t.Fatal("unexpected run time:", dur)
+	}
+}
@@ -0,0 +1,233 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by CircuitBreaker when the circuit is open
+var ErrCircuitOpen = errors.New("circuit open")
+
+// BreakerState is the state of CircuitBreaker
+type BreakerState int
+
+const (
+	// BreakerClosed allows every run
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every run with ErrCircuitOpen
+	BreakerOpen
+	// BreakerHalfOpen allows limited number of probes
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerPolicy defines when CircuitBreaker trips and recovers
+//
+// Runs returning context.Canceled are not counted.
+type BreakerPolicy struct {
+	// trips after failed this many times in a row, 0 disables it
+	ConsecutiveFailures uint64
+	// trips when failed/total >= FailureRatio, 0 disables it
+	FailureRatio float64
+	// FailureRatio is checked only if there are at least MinRequests runs
+	MinRequests uint64
+	// counters in closed state are cleared every Interval, 0 means never
+	Interval time.Duration
+	// how long to stay in open state before switching to half-open
+	Cooldown time.Duration
+	// max number of concurrent probes in half-open state, the circuit is
+	// closed after this many successful probes. Defaults to 1.
+	HalfOpenProbes uint64
+	// called after state changed, can be nil
+	OnStateChange func(from, to BreakerState)
+}
+
+// BreakerRunner is a Runner protected by a circuit breaker
+type BreakerRunner interface {
+	Runner
+	State() BreakerState
+}
+
+type stateChange struct {
+	from, to BreakerState
+}
+
+type breakerRunner struct {
+	lock sync.Mutex
+	p    BreakerPolicy
+
+	state BreakerState
+	// increased every state change, to drop results from previous state
+	gen       uint64
+	since     time.Time
+	total     uint64
+	failures  uint64
+	streak    uint64
+	probes    uint64
+	successes uint64
+
+	changes []stateChange
+	Runner
+}
+
+// CircuitBreaker creates a BreakerRunner which stops running r for a while
+// after too many failures
+//
+//     r := CircuitBreaker(callAPI, BreakerPolicy{
+//         ConsecutiveFailures: 5,
+//         Cooldown: 30*time.Second,
+//         OnStateChange: func(from, to BreakerState) {
+//             log.Printf("circuit breaker %s -> %s", from, to)
+//         },
+//     })
+func CircuitBreaker(r Runner, p BreakerPolicy) BreakerRunner {
+	if p.HalfOpenProbes == 0 {
+		p.HalfOpenProbes = 1
+	}
+	return &breakerRunner{
+		p:      p,
+		since:  clockOf(r).Now(),
+		Runner: r,
+	}
+}
+
+// setState MUST be called with lock held
+func (b *breakerRunner) setState(s BreakerState, now time.Time) {
+	if b.state == s {
+		return
+	}
+	b.changes = append(b.changes, stateChange{from: b.state, to: s})
+	b.state = s
+	b.gen++
+	b.since = now
+	b.total, b.failures, b.streak = 0, 0, 0
+	b.probes, b.successes = 0, 0
+}
+
+// update MUST be called with lock held
+func (b *breakerRunner) update(now time.Time) {
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.since) >= b.p.Cooldown {
+			b.setState(BreakerHalfOpen, now)
+		}
+	case BreakerClosed:
+		if b.p.Interval > 0 && now.Sub(b.since) >= b.p.Interval {
+			b.since = now
+			b.total, b.failures, b.streak = 0, 0, 0
+		}
+	}
+}
+
+// unlock releases the lock and fires callbacks
+func (b *breakerRunner) unlock() {
+	changes := b.changes
+	b.changes = nil
+	b.lock.Unlock()
+
+	if b.p.OnStateChange == nil {
+		return
+	}
+	for _, c := range changes {
+		b.p.OnStateChange(c.from, c.to)
+	}
+}
+
+func (b *breakerRunner) shouldTrip() bool {
+	if b.p.ConsecutiveFailures > 0 && b.streak >= b.p.ConsecutiveFailures {
+		return true
+	}
+	if b.p.FailureRatio > 0 && b.total > 0 && b.total >= b.p.MinRequests {
+		return float64(b.failures)/float64(b.total) >= b.p.FailureRatio
+	}
+	return false
+}
+
+// done MUST be called with lock held
+func (b *breakerRunner) done(err error, now time.Time) {
+	canceled := errors.Is(err, context.Canceled)
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probes--
+		switch {
+		case canceled:
+		case err != nil:
+			b.setState(BreakerOpen, now)
+		default:
+			b.successes++
+			if b.successes >= b.p.HalfOpenProbes {
+				b.setState(BreakerClosed, now)
+			}
+		}
+	case BreakerClosed:
+		if canceled {
+			return
+		}
+		b.total++
+		b.streak++
+		if err == nil {
+			b.streak = 0
+		} else {
+			b.failures++
+		}
+		if b.shouldTrip() {
+			b.setState(BreakerOpen, now)
+		}
+	}
+}
+
+func (b *breakerRunner) State() BreakerState {
+	b.lock.Lock()
+	defer b.unlock()
+
+	b.update(clockOf(b).Now())
+	return b.state
+}
+
+func (b *breakerRunner) Run() (err error) {
+	if IsCanceled(b) {
+		return context.Canceled
+	}
+
+	b.lock.Lock()
+	b.update(clockOf(b).Now())
+	switch b.state {
+	case BreakerOpen:
+		b.unlock()
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probes >= b.p.HalfOpenProbes {
+			b.unlock()
+			return ErrCircuitOpen
+		}
+		b.probes++
+	}
+	gen := b.gen
+	b.unlock()
+
+	err = b.Runner.Run()
+
+	b.lock.Lock()
+	if gen == b.gen {
+		b.done(err, clockOf(b).Now())
+	}
+	b.unlock()
+	return
+}
@@ -0,0 +1,136 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerConsecutive(t *testing.T) {
+	e := errors.New("")
+	fail := true
+	var changes []BreakerState
+	r := CircuitBreaker(
+		NoCancelRunner(func() error {
+			if fail {
+				return e
+			}
+			return nil
+		}),
+		BreakerPolicy{
+			ConsecutiveFailures: 3,
+			Cooldown:            10 * time.Millisecond,
+			OnStateChange: func(from, to BreakerState) {
+				changes = append(changes, to)
+			},
+		},
+	)
+
+	for i := 0; i < 3; i++ {
+		if err := r.Run(); err != e {
+			t.Fatalf("unexpected error in run #%d: %v", i, err)
+		}
+	}
+	if s := r.State(); s != BreakerOpen {
+		t.Fatal("expected open, got", s)
+	}
+	if err := r.Run(); err != ErrCircuitOpen {
+		t.Fatal("unexpected error:", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if s := r.State(); s != BreakerHalfOpen {
+		t.Fatal("expected half-open, got", s)
+	}
+	if err := r.Run(); err != e {
+		t.Fatal("unexpected error:", err)
+	}
+	if s := r.State(); s != BreakerOpen {
+		t.Fatal("expected open after failed probe, got", s)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	fail = false
+	if err := r.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if s := r.State(); s != BreakerClosed {
+		t.Fatal("expected closed, got", s)
+	}
+
+	expect := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(changes) != len(expect) {
+		t.Fatal("unexpected state changes:", changes)
+	}
+	for idx, s := range expect {
+		if changes[idx] != s {
+			t.Fatal("unexpected state changes:", changes)
+		}
+	}
+}
+
+func TestCircuitBreakerRatio(t *testing.T) {
+	e := errors.New("")
+	r := CircuitBreaker(
+		Counter(func(n uint64) error {
+			if n%2 == 0 {
+				return e
+			}
+			return nil
+		}),
+		BreakerPolicy{
+			FailureRatio: 0.5,
+			MinRequests:  4,
+			Cooldown:     time.Hour,
+		},
+	)
+
+	for i := 0; i < 3; i++ {
+		r.Run()
+		if s := r.State(); s != BreakerClosed {
+			t.Fatalf("expected closed after run #%d, got %s", i, s)
+		}
+	}
+	r.Run()
+	if s := r.State(); s != BreakerOpen {
+		t.Fatal("expected open, got", s)
+	}
+}
+
+func TestCircuitBreakerProbes(t *testing.T) {
+	wait := make(chan struct{})
+	start := make(chan struct{})
+	e := errors.New("")
+	fail := true
+	r := CircuitBreaker(
+		NoCancelRunner(func() error {
+			if fail {
+				return e
+			}
+			start <- struct{}{}
+			<-wait
+			return nil
+		}),
+		BreakerPolicy{ConsecutiveFailures: 1},
+	)
+
+	r.Run()
+	fail = false
+	done := make(chan error)
+	go func() { done <- r.Run() }()
+	<-start
+	if err := r.Run(); err != ErrCircuitOpen {
+		t.Fatal("expected to reject extra probe, got", err)
+	}
+	close(wait)
+	if err := <-done; err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if s := r.State(); s != BreakerClosed {
+		t.Fatal("expected closed, got", s)
+	}
+}
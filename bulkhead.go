@@ -0,0 +1,95 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrBulkheadFull is returned by Bulkhead when the waiting queue is full
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+// BulkheadRunner represents a Runner that limits concurrent runs
+type BulkheadRunner interface {
+	Runner
+	// number of running runs
+	InFlight() int
+	// number of runs waiting for a slot
+	Queued() int
+}
+
+type bulkheadRunner struct {
+	token    chan bool
+	queue    int64
+	queued   int64
+	inFlight int64
+	Runner
+}
+
+// Bulkhead creates a BulkheadRunner that runs r at most n at a time
+//
+// At most queue calls can wait for a slot, further calls return
+// ErrBulkheadFull immediately. Waiting is interrupted with context.Canceled when
+// r is canceled.
+//
+// It is like StatefulRunner, but allows n concurrent runs. It panics if n is
+// not positive.
+func Bulkhead(n, queue int, r Runner) (ret BulkheadRunner) {
+	if n <= 0 {
+		panic("non-positive concurrency for Bulkhead")
+	}
+	x := &bulkheadRunner{
+		token:  make(chan bool, n),
+		queue:  int64(queue),
+		Runner: r,
+	}
+	for i := 0; i < n; i++ {
+		x.token <- true
+	}
+
+	return x
+}
+
+func (b *bulkheadRunner) InFlight() int { return int(atomic.LoadInt64(&b.inFlight)) }
+func (b *bulkheadRunner) Queued() int   { return int(atomic.LoadInt64(&b.queued)) }
+
+func (b *bulkheadRunner) acquire() (err error) {
+	if err = b.Context().Err(); err != nil {
+		return
+	}
+
+	select {
+	case <-b.token:
+		return
+	default:
+	}
+
+	if atomic.AddInt64(&b.queued, 1) > b.queue {
+		atomic.AddInt64(&b.queued, -1)
+		return ErrBulkheadFull
+	}
+	defer atomic.AddInt64(&b.queued, -1)
+
+	select {
+	case <-b.token:
+		return
+	case <-b.Context().Done():
+		return b.Context().Err()
+	}
+}
+
+func (b *bulkheadRunner) Run() (err error) {
+	if err = b.acquire(); err != nil {
+		return
+	}
+
+	atomic.AddInt64(&b.inFlight, 1)
+	defer func() {
+		atomic.AddInt64(&b.inFlight, -1)
+		b.token <- true
+	}()
+	return b.Runner.Run()
+}
@@ -0,0 +1,84 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, desc string, f func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if f() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timeout waiting for", desc)
+}
+
+func TestBulkhead(t *testing.T) {
+	wait := make(chan struct{})
+	r := Bulkhead(2, 1, NoCancelRunner(func() error {
+		<-wait
+		return nil
+	}))
+
+	done := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() { done <- r.Run() }()
+	}
+	waitFor(t, "2 in flight and 1 queued", func() bool {
+		return r.InFlight() == 2 && r.Queued() == 1
+	})
+
+	if err := r.Run(); err != ErrBulkheadFull {
+		t.Fatal("unexpected error:", err)
+	}
+
+	close(wait)
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	if r.InFlight() != 0 || r.Queued() != 0 {
+		t.Fatalf("expected idle, got %d in flight and %d queued", r.InFlight(), r.Queued())
+	}
+}
+
+func TestBulkheadCancel(t *testing.T) {
+	r := Bulkhead(1, 1, CTXRunner(func(c context.Context) error {
+		<-c.Done()
+		return c.Err()
+	}))
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- r.Run() }()
+	}
+	waitFor(t, "1 queued", func() bool { return r.Queued() == 1 })
+
+	r.Cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != context.Canceled {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	if err := r.Run(); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestBulkheadNoSlot(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected to panic")
+		}
+	}()
+	Bulkhead(0, 1, NoCancelRunner(func() error { return nil }))
+}
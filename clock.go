@@ -0,0 +1,149 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock provides time to time-based Runners, so they can be tested without
+// really waiting
+//
+// Like Observer, Clock is passed through context. Combinators look for it in
+// the context of the Runner they wrap:
+//
+//     clk := fakeclock.New(time.Now())
+//     r := RunAtLeast(10*time.Second, UseClock(f, clk))
+//     go r.Run()
+//     clk.BlockUntil(1)
+//     clk.Advance(10*time.Second) // r.Run() returns
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is like time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{t: time.NewTimer(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time        { return t.t.C }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// RealClock is the Clock using package time, which is used if no Clock is
+// given
+var RealClock Clock = realClock{}
+
+type clockKey struct{}
+
+// WithClock creates a context carrying c
+func WithClock(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, c)
+}
+
+// ClockFrom returns the Clock in ctx, or RealClock if there's none
+func ClockFrom(ctx context.Context) Clock {
+	if c, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return c
+	}
+	return RealClock
+}
+
+func clockOf(r Runner) Clock {
+	return ClockFrom(r.Context())
+}
+
+// UseClock creates a Runner that runs r, and attaches c to its context
+//
+// Combinators wrapping the returned Runner use c. It's also a ContextRunner if
+// r is.
+func UseClock(r Runner, c Clock) Runner {
+	ctx := WithClock(r.Context(), c)
+	if x, ok := r.(ContextRunner); ok {
+		return &ctxRunner{
+			f:          x.RunContext,
+			funcRunner: funcRunner{ctx: ctx, cancel: r.Cancel, f: r.Run},
+		}
+	}
+	return NewRunner(ctx, r.Cancel, r.Run)
+}
+
+// clockContext is done when the Timer of a Clock fires or parent is done
+//
+// It does not embed a cancelCtx, so contexts derived from it copy its error,
+// which is context.DeadlineExceeded after the Timer fires.
+type clockContext struct {
+	parent   context.Context
+	deadline time.Time
+	done     chan struct{}
+	lock     sync.Mutex
+	err      error
+}
+
+func (c *clockContext) Deadline() (deadline time.Time, ok bool) {
+	if d, ok := c.parent.Deadline(); ok && d.Before(c.deadline) {
+		return d, ok
+	}
+	return c.deadline, true
+}
+
+func (c *clockContext) Done() <-chan struct{}             { return c.done }
+func (c *clockContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+func (c *clockContext) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+func (c *clockContext) cancel(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
+
+// withTimeout is like context.WithTimeout, but uses c to measure time
+func withTimeout(c Clock, ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if c == RealClock {
+		return context.WithTimeout(ctx, d)
+	}
+
+	ret := &clockContext{
+		parent:   ctx,
+		deadline: c.Now().Add(d),
+		done:     make(chan struct{}),
+	}
+	t := c.NewTimer(d)
+	spawn("WithTimeout", func() {
+		defer t.Stop()
+		select {
+		case <-t.C():
+			ret.cancel(context.DeadlineExceeded)
+		case <-ctx.Done():
+			ret.cancel(ctx.Err())
+		case <-ret.done:
+		}
+	})
+	return ret, func() { ret.cancel(context.Canceled) }
+}
@@ -0,0 +1,226 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/raohwork/ctxroutines"
+	"github.com/raohwork/ctxroutines/fakeclock"
+)
+
+func nop() error { return nil }
+
+// returned reports if ch receives a value before real time d
+func returned(ch <-chan error, d time.Duration) (err error, ok bool) {
+	select {
+	case err = <-ch:
+		return err, true
+	case <-time.After(d):
+		return nil, false
+	}
+}
+
+func TestClockFrom(t *testing.T) {
+	if ClockFrom(context.Background()) != RealClock {
+		t.Fatal("expected RealClock by default")
+	}
+
+	clk := fakeclock.New(time.Now())
+	r := UseClock(CTXRunner(func(ctx context.Context) error { return nil }), clk)
+	if ClockFrom(r.Context()) != clk {
+		t.Fatal("expected clock attached to context")
+	}
+	if _, ok := r.(ContextRunner); !ok {
+		t.Fatal("expected ContextRunner to be kept")
+	}
+}
+
+func TestRunAtLeastWithClock(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	r := RunAtLeast(10*time.Second, UseClock(NoCancelRunner(nop), clk))
+
+	ch := make(chan error, 1)
+	go func() { ch <- r.Run() }()
+	clk.BlockUntil(1)
+	clk.Advance(9 * time.Second)
+	if _, ok := returned(ch, 10*time.Millisecond); ok {
+		t.Fatal("returned too early")
+	}
+
+	clk.Advance(time.Second)
+	if err, ok := returned(ch, time.Second); !ok || err != nil {
+		t.Fatal("unexpected result:", err, ok)
+	}
+}
+
+func TestNoLessThanWithClock(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	r := NoLessThan(time.Minute, UseClock(NoCancelRunner(nop), clk))
+
+	if err := r.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ch := make(chan error, 1)
+	go func() { ch <- r.Run() }()
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute)
+	if err, ok := returned(ch, time.Second); !ok || err != nil {
+		t.Fatal("unexpected result:", err, ok)
+	}
+}
+
+func TestWithTimeoutWithClock(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	r := WithTimeout(time.Hour, UseClock(CTXRunner(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), clk))
+
+	ch := make(chan error, 1)
+	go func() { ch <- r.Run() }()
+	clk.BlockUntil(1)
+	clk.Advance(time.Hour)
+	err, ok := returned(ch, time.Second)
+	if !ok || err != ErrRunTimeout {
+		t.Fatal("unexpected result:", err, ok)
+	}
+}
+
+func TestWithTimeoutWithClockDerived(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	errs := make(chan error, 1)
+	r := WithTimeout(time.Hour, UseClock(CTXRunner(func(ctx context.Context) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		<-ctx.Done()
+		errs <- ctx.Err()
+		return ctx.Err()
+	}), clk))
+
+	go r.Run()
+	clk.BlockUntil(1)
+	clk.Advance(time.Hour)
+	err, ok := returned(errs, time.Second)
+	if !ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expected derived context to expire, got", err, ok)
+	}
+}
+
+func TestEveryWithClock(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	cnt := make(chan struct{}, 10)
+	r := Every(time.Hour, UseClock(CTXRunner(func(ctx context.Context) error {
+		cnt <- struct{}{}
+		return nil
+	}), clk))
+	defer r.Cancel()
+
+	go r.Run()
+	<-cnt
+	for i := 0; i < 3; i++ {
+		clk.BlockUntil(1)
+		clk.Advance(time.Hour)
+		<-cnt
+	}
+}
+
+func TestEveryCatchUpWithClock(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	cnt := make(chan struct{}, 10)
+	hold := make(chan struct{})
+	var n int64
+	r := Every(time.Hour, UseClock(CTXRunner(func(ctx context.Context) error {
+		cnt <- struct{}{}
+		if atomic.AddInt64(&n, 1) == 2 {
+			<-hold
+		}
+		return nil
+	}), clk), WithCatchUp())
+	defer r.Cancel()
+
+	go r.Run()
+	<-cnt
+	clk.BlockUntil(1)
+	clk.Advance(5 * time.Hour)
+	<-cnt
+	// missed ticks are dispatched while first of them is running
+	clk.BlockUntil(1)
+	close(hold)
+
+	for i := 0; i < 4; i++ {
+		select {
+		case <-cnt:
+		case <-time.After(time.Second):
+			t.Fatalf("expected 5 runs to catch up, got %d", i+1)
+		}
+	}
+	clk.BlockUntil(1)
+	if l := len(cnt); l != 0 {
+		t.Fatalf("expected 5 runs to catch up, got %d", 5+l)
+	}
+}
+
+func TestDebounceWithClock(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	cnt := make(chan struct{}, 10)
+	r := Debounce(time.Second, UseClock(CTXRunner(func(ctx context.Context) error {
+		cnt <- struct{}{}
+		return nil
+	}), clk))
+	defer r.Cancel()
+
+	go r.Run()
+	r.Trigger()
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	<-cnt
+}
+
+func TestCircuitBreakerWithClock(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	e := errors.New("")
+	r := CircuitBreaker(UseClock(NoCancelRunner(func() error { return e }), clk), BreakerPolicy{
+		ConsecutiveFailures: 1,
+		Cooldown:            time.Minute,
+	})
+
+	if err := r.Run(); err != e {
+		t.Fatal("unexpected error:", err)
+	}
+	if r.State() != BreakerOpen {
+		t.Fatal("expected open, got", r.State())
+	}
+	clk.Advance(time.Minute)
+	if r.State() != BreakerHalfOpen {
+		t.Fatal("expected half-open, got", r.State())
+	}
+}
+
+func TestSupervisorWithClock(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	e := errors.New("")
+	cnt := 0
+	s := NewSupervisor(OneForOne, 1, time.Minute)
+	s.Add(UseClock(NoCancelRunner(func() error {
+		if cnt++; cnt > 5 {
+			return nil
+		}
+		clk.Advance(time.Minute)
+		return e
+	}), clk))
+
+	if err := s.Run(); err != nil {
+		t.Fatal("expected restarts to be out of window, got", err)
+	}
+	if cnt != 6 {
+		t.Fatalf("expected ran 6 times, got %d", cnt)
+	}
+}
@@ -0,0 +1,97 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"errors"
+	"runtime/debug"
+	"sync"
+)
+
+// errGoexit is received by callers joined a run calling runtime.Goexit
+var errGoexit = errors.New("runtime.Goexit was called")
+
+type coalescedCall struct {
+	done chan struct{}
+	err  error
+	// number of callers joined, guarded by lock of CoalesceGroup
+	dups int
+}
+
+// CoalesceGroup coalesces concurrent runs with same key
+//
+// Zero value is ready to use.
+type CoalesceGroup struct {
+	lock  sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// finish removes c from g, and returns number of callers joined
+func (g *CoalesceGroup) finish(key string, c *coalescedCall) (dups int) {
+	g.lock.Lock()
+	delete(g.calls, key)
+	dups = c.dups
+	g.lock.Unlock()
+	close(c.done)
+	return
+}
+
+// Do runs r if there's no in-flight run with same key. Otherwise it waits the
+// in-flight run and returns its error. shared is true if the result is shared
+// with other callers, including the caller which runs r if others joined.
+//
+// If r panics, the panic is propagated to the caller which runs r, and others
+// receive an ErrPanic. If r calls runtime.Goexit, others receive an error.
+func (g *CoalesceGroup) Do(key string, r Runner) (err error, shared bool) {
+	g.lock.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*coalescedCall{}
+	}
+	if c, ok := g.calls[key]; ok {
+		c.dups++
+		g.lock.Unlock()
+		<-c.done
+		return c.err, true
+	}
+	c := &coalescedCall{done: make(chan struct{})}
+	g.calls[key] = c
+	g.lock.Unlock()
+
+	normalReturn := false
+	defer func() {
+		if normalReturn {
+			return
+		}
+		v := recover()
+		c.err = errGoexit
+		if v != nil {
+			c.err = ErrPanic{Value: v, Stack: debug.Stack()}
+		}
+		g.finish(key, c)
+		if v != nil {
+			panic(v)
+		}
+	}()
+	c.err = r.Run()
+	normalReturn = true
+	return c.err, g.finish(key, c) > 0
+}
+
+// Runner creates a Runner that runs r through g with key
+func (g *CoalesceGroup) Runner(key string, r Runner) Runner {
+	return FromRunner(r, func() (err error) {
+		err, _ = g.Do(key, r)
+		return
+	})
+}
+
+// Coalesce creates a Runner that concurrent Run() calls share one run of r
+//
+// Unlike StatefulRunner.TryRun, callers joining the in-flight run wait it
+// and receive its error. Use CoalesceGroup if you need to coalesce by key, like
+// refreshing same resource.
+func Coalesce(r Runner) Runner {
+	return (&CoalesceGroup{}).Runner("", r)
+}
@@ -0,0 +1,192 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCoalesce(t *testing.T) {
+	e := errors.New("")
+	var cnt int64
+	start := make(chan struct{})
+	wait := make(chan struct{})
+	g := &CoalesceGroup{}
+	r := g.Runner("", NoCancelRunner(func() error {
+		if atomic.AddInt64(&cnt, 1) == 1 {
+			close(start)
+		}
+		<-wait
+		return e
+	}))
+
+	const n = 5
+	errs := make(chan error, n)
+	go func() { errs <- r.Run() }()
+	<-start
+
+	for i := 1; i < n; i++ {
+		go func() { errs <- r.Run() }()
+	}
+	waitFor(t, "callers to join", func() bool {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		return g.calls[""].dups == n-1
+	})
+	close(wait)
+
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != e {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	if v := atomic.LoadInt64(&cnt); v != 1 {
+		t.Fatalf("expected runs to be coalesced, got %d runs", v)
+	}
+
+	if err := r.Run(); err != e {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestCoalesceGroup(t *testing.T) {
+	g := &CoalesceGroup{}
+	wait := make(chan struct{})
+	start := make(chan struct{})
+	a := NoCancelRunner(func() error { close(start); <-wait; return nil })
+	b := NoCancelRunner(func() error { return nil })
+
+	done := make(chan bool)
+	go func() { _, shared := g.Do("a", a); done <- shared }()
+	<-start
+
+	if _, shared := g.Do("b", b); shared {
+		t.Fatal("expected different keys not to be coalesced")
+	}
+	close(wait)
+	if <-done {
+		t.Fatal("expected first caller not to be shared")
+	}
+
+	// the caller running r is shared once others joined
+	wait = make(chan struct{})
+	start = make(chan struct{})
+	go func() { _, shared := g.Do("a", a); done <- shared }()
+	<-start
+	joined := make(chan bool)
+	go func() { _, shared := g.Do("a", a); joined <- shared }()
+	waitFor(t, "caller to join", func() bool {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		return g.calls["a"].dups == 1
+	})
+	close(wait)
+	if !<-done || !<-joined {
+		t.Fatal("expected both callers to be shared")
+	}
+}
+
+func TestCoalescePanic(t *testing.T) {
+	r := Coalesce(NoCancelRunner(func() error { panic("boom") }))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected to propagate panic")
+			}
+		}()
+		r.Run()
+	}()
+
+	// the group must be usable after panic, so r runs again
+	var cnt int64
+	r = Coalesce(NoCancelRunner(func() error {
+		atomic.AddInt64(&cnt, 1)
+		panic("boom")
+	}))
+	for i := 0; i < 2; i++ {
+		func() {
+			defer func() {
+				if v := recover(); v != "boom" {
+					t.Fatal("unexpected panic:", v)
+				}
+			}()
+			r.Run()
+		}()
+	}
+	if cnt != 2 {
+		t.Fatal("expected r to run again after panic, got", cnt)
+	}
+}
+
+func TestCoalescePanicWaiter(t *testing.T) {
+	g := &CoalesceGroup{}
+	start := make(chan struct{})
+	wait := make(chan struct{})
+	r := NoCancelRunner(func() error {
+		close(start)
+		<-wait
+		panic("boom")
+	})
+
+	go func() {
+		defer func() { recover() }()
+		g.Do("", r)
+	}()
+	<-start
+
+	errs := make(chan error)
+	go func() {
+		err, _ := g.Do("", r)
+		errs <- err
+	}()
+	waitFor(t, "caller to join", func() bool {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		return g.calls[""].dups == 1
+	})
+	close(wait)
+
+	var p ErrPanic
+	if err := <-errs; !errors.As(err, &p) || p.Value != "boom" {
+		t.Fatal("expected ErrPanic, got", err)
+	}
+}
+
+func TestCoalesceGoexit(t *testing.T) {
+	g := &CoalesceGroup{}
+	start := make(chan struct{})
+	wait := make(chan struct{})
+	r := NoCancelRunner(func() error {
+		close(start)
+		<-wait
+		runtime.Goexit()
+		return nil
+	})
+
+	go g.Do("", r)
+	<-start
+
+	errs := make(chan error)
+	go func() {
+		err, _ := g.Do("", r)
+		errs <- err
+	}()
+	waitFor(t, "caller to join", func() bool {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		return g.calls[""].dups == 1
+	})
+	close(wait)
+
+	if err := <-errs; err != errGoexit {
+		t.Fatal("unexpected error:", err)
+	}
+	if err, _ := g.Do("", NoCancelRunner(func() error { return nil })); err != nil {
+		t.Fatal("expected key to be released, got", err)
+	}
+}
@@ -63,14 +63,38 @@ func Recorded(r Runner) (ret RecordedRunner) {
 //     r := TryAtMost(3, f)
 //     r.Run() // run f 3 times, and returs nil
 func TryAtMost(n uint64, f Runner) (ret Runner) {
-	r := Recorded(f)
+	return tryAtMost(n, f, func(RecordedRunner, error) {})
+}
+
+// TryAtMostWithBackoff is like TryAtMost, but waits between each try according
+// to b
+//
+// Waiting is interrupted once f is canceled, and b is reset after f succeeded.
+func TryAtMostWithBackoff(n uint64, f Runner, b Backoff) (ret Runner) {
+	return tryAtMost(n, f, func(r RecordedRunner, err error) {
+		if err == nil {
+			b.Reset()
+			return
+		}
+		if r.Count() < n {
+			sleep(r.Context(), b.Next())
+		}
+	})
+}
+
+// tryAtMost implements TryAtMost, after is called after each run of f
+func tryAtMost(n uint64, f Runner, after func(RecordedRunner, error)) (ret Runner) {
+	r := Recorded(FromRunner(f, func() error { return runChild(f) }))
 	return FromRunner(r, func() (err error) {
+		o := observerOf(r)
 		for r.Count() < n {
 			if IsCanceled(r) {
 				return context.Canceled
 			}
 
+			o.Attempt(r.Count() + 1)
 			err = r.Run()
+			after(r, err)
 			if err == nil {
 				return
 			}
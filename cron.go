@@ -0,0 +1,313 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSpec is a parsed cron expression
+type CronSpec struct {
+	second, minute, hour, dom, month, dow uint64
+	// dom or dow is "*" or "?"
+	domStar, dowStar bool
+	// second, minute or hour field begins with "*"
+	wildcard bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronSecond = cronField{name: "second", min: 0, max: 59}
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is also sunday
+	cronDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("invalid " + f.name + ": " + s)
+	}
+	if v < f.min || v > f.max {
+		return 0, errors.New(f.name + " out of range: " + s)
+	}
+	return v, nil
+}
+
+// parse parses a field, star is true if it's "*" or "?"
+func (f cronField) parse(s string) (bits uint64, star bool, err error) {
+	star = s == "*" || s == "?"
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if idx := strings.IndexByte(part, '/'); idx >= 0 {
+			rng = part[:idx]
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step <= 0 {
+				return 0, false, errors.New("invalid step of " + f.name + ": " + part)
+			}
+		}
+
+		begin, end := f.min, f.max
+		switch idx := strings.IndexByte(rng, '-'); {
+		case rng == "*" || rng == "?":
+		case idx >= 0:
+			if begin, err = f.value(rng[:idx]); err != nil {
+				return
+			}
+			if end, err = f.value(rng[idx+1:]); err != nil {
+				return
+			}
+			if begin > end {
+				return 0, false, errors.New("invalid range of " + f.name + ": " + rng)
+			}
+		default:
+			if begin, err = f.value(rng); err != nil {
+				return
+			}
+			if step == 1 {
+				end = begin
+			}
+		}
+
+		for v := begin; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return
+}
+
+// ParseCron parses a cron expression
+//
+// It accepts standard 5 fields (minute, hour, day of month, month, day of
+// week), or 6 fields with leading second. Each field can be "*", a number,
+// a range "a-b", a step "*/n" or "a-b/n", or a comma-separated list of them.
+// Month and day of week can also be names like "jan" or "mon".
+//
+// If both day of month and day of week are restricted, it matches either of
+// them, like standard cron.
+//
+// Descriptors like "@daily" or "@hourly" are also supported.
+func ParseCron(spec string) (ret *CronSpec, err error) {
+	if s, ok := cronDescriptors[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		spec = s
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, errors.New("expected 5 or 6 fields in cron spec: " + spec)
+	}
+
+	ret = &CronSpec{}
+	for _, f := range fields[:3] {
+		if strings.HasPrefix(f, "*") {
+			ret.wildcard = true
+		}
+	}
+	if ret.second, _, err = cronSecond.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if ret.minute, _, err = cronMinute.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if ret.hour, _, err = cronHour.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if ret.dom, ret.domStar, err = cronDom.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if ret.month, _, err = cronMonth.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	if ret.dow, ret.dowStar, err = cronDow.parse(fields[5]); err != nil {
+		return nil, err
+	}
+	if ret.dow&(1<<7) != 0 {
+		ret.dow |= 1
+	}
+
+	return
+}
+
+func hasBit(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+func (c *CronSpec) dayMatches(t time.Time) bool {
+	dom := hasBit(c.dom, t.Day())
+	dow := hasBit(c.dow, int(t.Weekday()))
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first matched time after t, in the location of t
+//
+// It returns zero time if nothing matched in 5 years (like "0 0 30 2 *").
+//
+// Daylight saving time is handled like vixie cron. If the second, minute or
+// hour field begins with "*", the spec is matched against the clock, so matched
+// time in skipped hour is skipped, and matched time in repeated hour is matched
+// twice. Otherwise, matched time in skipped hour is replaced by the moment the
+// clock jumps forward, and matched time in repeated hour is matched only once.
+func (c *CronSpec) Next(t time.Time) time.Time {
+	if c.wildcard {
+		return c.next(t)
+	}
+
+	loc := t.Location()
+	w := wallClock(t)
+	for {
+		if w = c.next(w); w.IsZero() {
+			return w
+		}
+		if ret := fromWallClock(w, loc); ret.After(t) {
+			return ret
+		}
+	}
+}
+
+// wallClock returns the time in UTC which shows same clock as t
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// fromWallClock returns the first moment in loc showing w, which is returned by
+// wallClock
+//
+// If w is skipped due to DST, it returns the moment the clock jumps over w.
+func fromWallClock(w time.Time, loc *time.Location) time.Time {
+	ret := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), w.Nanosecond(), loc)
+	start, end := ret.ZoneBounds()
+	if x := wallClock(ret); !x.Equal(w) {
+		if x.After(w) {
+			return start
+		}
+		return end
+	}
+
+	// w might be shown earlier in previous zone, when DST ends
+	if !start.IsZero() {
+		_, cur := ret.Zone()
+		_, prev := start.Add(-time.Second).Zone()
+		x := ret.Add(time.Duration(cur-prev) * time.Second)
+		if x.Before(start) && wallClock(x).Equal(w) {
+			return x
+		}
+	}
+	return ret
+}
+
+// next is Next without handling daylight saving time
+func (c *CronSpec) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	limit := t.Year() + 5
+
+	// truncated is true once t is truncated to the beginning of a period
+	truncated := false
+wrap:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+
+	for !hasBit(c.month, int(t.Month())) {
+		if !truncated {
+			truncated = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !c.dayMatches(t) {
+		if !truncated {
+			truncated = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// midnight might not exist due to DST
+		if h := t.Hour(); h != 0 {
+			if h > 12 {
+				t = t.Add(time.Duration(24-h) * time.Hour)
+			} else {
+				t = t.Add(time.Duration(-h) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for !hasBit(c.hour, t.Hour()) {
+		if !truncated {
+			truncated = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for !hasBit(c.minute, t.Minute()) {
+		if !truncated {
+			truncated = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for !hasBit(c.second, t.Second()) {
+		if !truncated {
+			truncated = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,177 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func testCronNext(spec, tz, from string, expect ...string) func(*testing.T) {
+	return func(t *testing.T) {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			t.Fatal("cannot load location:", err)
+		}
+		c, err := ParseCron(spec)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		const layout = "2006-01-02 15:04:05 MST"
+		cur, err := time.ParseInLocation("2006-01-02 15:04:05", from, loc)
+		if err != nil {
+			t.Fatal("incorrect test case:", err)
+		}
+		for idx, e := range expect {
+			cur = c.Next(cur)
+			if v := cur.Format(layout); v != e {
+				t.Fatalf("expected #%d to be %s, got %s", idx, e, v)
+			}
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	t.Run("weekday", testCronNext(
+		"30 2 * * mon-fri", "Asia/Taipei", "2021-07-30 02:30:00",
+		"2021-08-02 02:30:00 CST",
+		"2021-08-03 02:30:00 CST",
+	))
+	t.Run("seconds", testCronNext(
+		"*/20 * * * * *", "UTC", "2021-07-30 23:59:30",
+		"2021-07-30 23:59:40 UTC",
+		"2021-07-31 00:00:00 UTC",
+	))
+	t.Run("dom-or-dow", testCronNext(
+		"0 0 13 * fri", "UTC", "2021-08-01 00:00:00",
+		"2021-08-06 00:00:00 UTC",
+		"2021-08-13 00:00:00 UTC",
+		"2021-08-20 00:00:00 UTC",
+	))
+	t.Run("leap", testCronNext(
+		"@yearly", "UTC", "2021-07-30 00:00:00",
+		"2022-01-01 00:00:00 UTC",
+	))
+	t.Run("feb-29", testCronNext(
+		"0 0 29 feb *", "UTC", "2021-01-01 00:00:00",
+		"2024-02-29 00:00:00 UTC",
+	))
+	t.Run("dst-begin", testCronNext(
+		"30 2 * * *", "America/New_York", "2026-03-07 03:00:00",
+		"2026-03-08 03:00:00 EDT",
+		"2026-03-09 02:30:00 EDT",
+	))
+	t.Run("dst-end", testCronNext(
+		"30 1 * * *", "America/New_York", "2026-10-31 03:00:00",
+		"2026-11-01 01:30:00 EDT",
+		"2026-11-02 01:30:00 EST",
+	))
+	t.Run("dst-begin-wildcard", testCronNext(
+		"30 * * * *", "America/New_York", "2026-03-08 01:00:00",
+		"2026-03-08 01:30:00 EST",
+		"2026-03-08 03:30:00 EDT",
+	))
+	t.Run("dst-end-wildcard", testCronNext(
+		"0 * * * *", "America/New_York", "2026-11-01 00:30:00",
+		"2026-11-01 01:00:00 EDT",
+		"2026-11-01 01:00:00 EST",
+		"2026-11-01 02:00:00 EST",
+	))
+}
+
+func TestCronInvalid(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * * foo",
+	}
+	for _, s := range specs {
+		if _, err := ParseCron(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestCronNever(t *testing.T) {
+	c, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if v := c.Next(time.Now()); !v.IsZero() {
+		t.Fatal("expected zero time, got", v)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	ch := make(chan time.Time, 1)
+	r, err := Schedule("* * * * * *", time.UTC, CTXRunner(func(context.Context) error {
+		ch <- time.Now()
+		return nil
+	}))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- r.Run() }()
+	now := <-ch
+	if n := now.Nanosecond(); n > int(100*time.Millisecond) {
+		t.Error("expected to run at the beginning of a second, got", now)
+	}
+
+	r.Cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatal("unexpected error:", err)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("expected to stop promptly")
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	wait := make(chan struct{})
+	ch := make(chan int, 10)
+	cnt := 0
+	f := NoCancelRunner(func() error {
+		cnt++
+		ch <- cnt
+		<-wait
+		return nil
+	})
+
+	d := &dispatcher{r: f, cfg: newTickConfig([]TickOption{WithOverlap(OverlapQueue)})}
+	d.dispatch(false)
+	d.dispatch(false)
+	d.dispatch(false)
+	<-ch
+	close(wait)
+	d.wg.Wait()
+	if cnt != 3 {
+		t.Fatalf("expected queued runs to run, got %d runs", cnt)
+	}
+
+	d = &dispatcher{r: f, cfg: newTickConfig(nil)}
+	cnt = 0
+	ch = make(chan int, 10)
+	wait = make(chan struct{})
+	d.dispatch(false)
+	<-ch
+	d.dispatch(false)
+	close(wait)
+	d.wg.Wait()
+	if cnt != 1 {
+		t.Fatalf("expected overlapped run to be skipped, got %d runs", cnt)
+	}
+}
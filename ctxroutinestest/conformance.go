@@ -0,0 +1,156 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutinestest
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raohwork/ctxroutines"
+)
+
+// ConformanceTimeout is how long RunnerConformance waits for Run() to return
+// after canceled
+var ConformanceTimeout = time.Second
+
+// runWithin runs r in separated goroutine, and reports if it returns within
+// ConformanceTimeout
+func runWithin(r ctxroutines.Runner) (err error, ok bool) {
+	ch := make(chan error, 1)
+	go func() { ch <- r.Run() }()
+	select {
+	case err = <-ch:
+		return err, true
+	case <-time.After(ConformanceTimeout):
+		return nil, false
+	}
+}
+
+// RunnerConformance checks if Runners created by factory follow the contract
+// of ctxroutines.Runner
+//
+//     func TestMyRunner(t *testing.T) {
+//         ctxroutinestest.RunnerConformance(t, func() ctxroutines.Runner {
+//             return NewMyRunner()
+//         })
+//     }
+//
+// factory is called once for each check. Runners it creates should block in
+// Run() until canceled, like a server or Loop. Run the test with -race to
+// check concurrent Run() calls.
+func RunnerConformance(t *testing.T, factory func() ctxroutines.Runner) {
+	t.Helper()
+
+	t.Run("ContextDoneAfterCancel", func(t *testing.T) {
+		r := factory()
+		if ctxroutines.IsCanceled(r) {
+			t.Fatal("Context() is done before Cancel()")
+		}
+		r.Cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(ConformanceTimeout):
+			t.Fatal("Context() is not done after Cancel()")
+		}
+	})
+
+	t.Run("IdempotentCancel", func(t *testing.T) {
+		r := factory()
+		defer func() {
+			if v := recover(); v != nil {
+				t.Fatal("calling Cancel() twice panics:", v)
+			}
+		}()
+		r.Cancel()
+		r.Cancel()
+	})
+
+	t.Run("RunAfterCancel", func(t *testing.T) {
+		r := factory()
+		r.Cancel()
+		err, ok := runWithin(r)
+		if !ok {
+			t.Fatal("Run() blocks after Cancel()")
+		}
+		if err == nil {
+			t.Fatal("Run() returns nil after Cancel()")
+		}
+	})
+
+	t.Run("CancelWhileRunning", func(t *testing.T) {
+		r := factory()
+		ch := make(chan error, 1)
+		go func() { ch <- r.Run() }()
+		time.Sleep(10 * time.Millisecond)
+		r.Cancel()
+
+		select {
+		case err := <-ch:
+			if err == nil {
+				t.Fatal("Run() returns nil after Cancel()")
+			}
+		case <-time.After(ConformanceTimeout):
+			t.Fatal("Run() does not return after Cancel()")
+		}
+	})
+
+	t.Run("ConcurrentRun", func(t *testing.T) {
+		const n = 8
+		r := factory()
+		errs := make(chan error, n)
+		wg := sync.WaitGroup{}
+		wg.Add(n)
+		for i := 0; i < n; i++ {
+			go func() {
+				defer wg.Done()
+				errs <- r.Run()
+			}()
+		}
+		time.Sleep(10 * time.Millisecond)
+		r.Cancel()
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(ConformanceTimeout):
+			t.Fatal("concurrent Run() calls do not return after Cancel()")
+		}
+		close(errs)
+		for err := range errs {
+			if err == nil {
+				t.Fatal("Run() returns nil after Cancel()")
+			}
+		}
+	})
+
+	t.Run("NoGoroutineLeak", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		r := factory()
+		ch := make(chan error, 1)
+		go func() { ch <- r.Run() }()
+		time.Sleep(10 * time.Millisecond)
+		r.Cancel()
+		select {
+		case <-ch:
+		case <-time.After(ConformanceTimeout):
+			t.Fatal("Run() does not return after Cancel()")
+		}
+
+		deadline := time.Now().Add(ConformanceTimeout)
+		for runtime.NumGoroutine() > before {
+			if time.Now().After(deadline) {
+				t.Fatalf("goroutines leaked: %d before, %d after\n%s",
+					before, runtime.NumGoroutine(), allStacks())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutinestest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/raohwork/ctxroutines"
+)
+
+func block(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestConformance(t *testing.T) {
+	cases := map[string]func() Runner{
+		"CTXRunner": func() Runner { return CTXRunner(block) },
+		"Loop": func() Runner {
+			return Loop(CTXRunner(func(ctx context.Context) error { return nil }))
+		},
+		"Every": func() Runner {
+			return Every(time.Millisecond, CTXRunner(func(ctx context.Context) error { return nil }))
+		},
+		"Debounce": func() Runner {
+			return Debounce(time.Millisecond, CTXRunner(block))
+		},
+		"WithTimeout": func() Runner {
+			return WithTimeout(time.Hour, CTXRunner(block))
+		},
+		"Pool": func() Runner {
+			return NewPool(2, 2, PoolDrain, nil)
+		},
+		"Supervisor": func() Runner {
+			s := NewSupervisor(OneForOne, 1, time.Second)
+			s.AddFunc(func() Runner { return CTXRunner(block) })
+			return s
+		},
+	}
+
+	for name, f := range cases {
+		t.Run(name, func(t *testing.T) {
+			RunnerConformance(t, f)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutinestest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raohwork/ctxroutines"
+)
+
+// Step is the outcome of a Run() call of FakeRunner
+//
+// Run() waits Delay, then blocks until canceled if Block is true, panics if
+// Panic is not nil, or returns Err.
+type Step struct {
+	Err   error
+	Delay time.Duration
+	Panic interface{}
+	Block bool
+}
+
+// Return creates a Step returns err
+func Return(err error) Step { return Step{Err: err} }
+
+// Delay creates a Step returns err after d
+func Delay(d time.Duration, err error) Step { return Step{Err: err, Delay: d} }
+
+// Panic creates a Step panics with v
+func Panic(v interface{}) Step { return Step{Panic: v} }
+
+// Block creates a Step blocks until canceled
+func Block() Step { return Step{Block: true} }
+
+// Call records a Run() call of FakeRunner
+type Call struct {
+	Start time.Time
+	// zero if still running
+	End time.Time
+	Err error
+}
+
+// FakeRunner is a Runner runs scripted steps
+//
+//     f := NewFakeRunner(Return(e), Delay(time.Second, e), Return(nil))
+//     r := Retry(f)
+//     r.Run()
+//     f.CalledTimes(t, 3)
+//
+// n-th Run() call runs n-th step, and last step is repeated once all steps are
+// used. Run() returns nil if there's no step. Waiting is interrupted by
+// Cancel(), Run() returns context.Canceled in that case.
+type FakeRunner struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	steps  []Step
+
+	lock    sync.Mutex
+	calls   []Call
+	running int
+	max     int
+}
+
+// NewFakeRunner creates a FakeRunner runs steps
+func NewFakeRunner(steps ...Step) *FakeRunner {
+	return NewFakeRunnerWith(context.Background(), steps...)
+}
+
+// NewFakeRunnerWith is like NewFakeRunner, but with predefined context
+//
+// Clock in ctx is used to wait and record time, so it works with Harness:
+//
+//     f := NewFakeRunnerWith(ctxroutines.WithClock(ctx, h.Clock), steps...)
+func NewFakeRunnerWith(ctx context.Context, steps ...Step) *FakeRunner {
+	ctx, cancel := context.WithCancel(ctx)
+	return &FakeRunner{
+		ctx:    ctx,
+		cancel: cancel,
+		steps:  steps,
+	}
+}
+
+func (f *FakeRunner) Context() context.Context { return f.ctx }
+func (f *FakeRunner) Cancel()                  { f.cancel() }
+
+// start records a call, MUST be called with lock held
+func (f *FakeRunner) start(now time.Time) (idx int, step Step) {
+	idx = len(f.calls)
+	f.calls = append(f.calls, Call{Start: now})
+	if f.running++; f.running > f.max {
+		f.max = f.running
+	}
+
+	if l := len(f.steps); l > 0 {
+		if idx >= l {
+			return idx, f.steps[l-1]
+		}
+		step = f.steps[idx]
+	}
+	return
+}
+
+func (f *FakeRunner) Run() (err error) {
+	clk := ctxroutines.ClockFrom(f.ctx)
+	f.lock.Lock()
+	idx, step := f.start(clk.Now())
+	f.lock.Unlock()
+
+	defer func() {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		f.calls[idx].End = clk.Now()
+		f.calls[idx].Err = err
+		f.running--
+	}()
+
+	if step.Delay > 0 {
+		t := clk.NewTimer(step.Delay)
+		defer t.Stop()
+		select {
+		case <-t.C():
+		case <-f.ctx.Done():
+			return f.ctx.Err()
+		}
+	}
+	if step.Block {
+		<-f.ctx.Done()
+		return f.ctx.Err()
+	}
+	if step.Panic != nil {
+		panic(step.Panic)
+	}
+	return step.Err
+}
+
+// Calls returns every recorded call
+func (f *FakeRunner) Calls() []Call {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	ret := make([]Call, len(f.calls))
+	copy(ret, f.calls)
+	return ret
+}
+
+// Count returns number of Run() calls
+func (f *FakeRunner) Count() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.calls)
+}
+
+// Running returns number of running Run() calls
+func (f *FakeRunner) Running() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.running
+}
+
+// Concurrency returns max number of Run() calls running at same time
+func (f *FakeRunner) Concurrency() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.max
+}
+
+// CalledTimes fails the test if Run() is not called n times
+func (f *FakeRunner) CalledTimes(t testing.TB, n int) {
+	t.Helper()
+	if c := f.Count(); c != n {
+		t.Fatalf("expected Run() to be called %d times, got %d", n, c)
+	}
+}
+
+// MaxConcurrent fails the test if more than n Run() calls ran at same time
+func (f *FakeRunner) MaxConcurrent(t testing.TB, n int) {
+	t.Helper()
+	if c := f.Concurrency(); c > n {
+		t.Fatalf("expected at most %d concurrent Run() calls, got %d", n, c)
+	}
+}
@@ -0,0 +1,88 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutinestest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/raohwork/ctxroutines"
+)
+
+func TestFakeRunnerSteps(t *testing.T) {
+	e := errors.New("")
+	f := NewFakeRunner(Return(e), Return(e), Return(nil))
+	if err := Retry(f).Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	f.CalledTimes(t, 3)
+
+	// last step is repeated
+	if err := f.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	calls := f.Calls()
+	if len(calls) != 4 || calls[0].Err != e || calls[2].Err != nil {
+		t.Fatalf("unexpected calls: %+v", calls)
+	}
+	for _, c := range calls {
+		if c.End.Before(c.Start) {
+			t.Fatalf("unexpected timestamps: %+v", c)
+		}
+	}
+
+	if err := NewFakeRunner().Run(); err != nil {
+		t.Fatal("expected nil without steps, got", err)
+	}
+}
+
+func TestFakeRunnerPanic(t *testing.T) {
+	f := NewFakeRunner(Panic("boom"))
+	err := Recover(f).Run()
+	var p ErrPanic
+	if !errors.As(err, &p) || p.Value != "boom" {
+		t.Fatal("unexpected error:", err)
+	}
+	if f.Running() != 0 {
+		t.Fatal("panicked call should be recorded as returned")
+	}
+}
+
+func TestFakeRunnerBlock(t *testing.T) {
+	f := NewFakeRunner(Block())
+	go func() {
+		for i := 0; i < 1000 && f.Running() < 3; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		f.Cancel()
+	}()
+	errs := Run(f, f, f)
+	for _, err := range errs {
+		if err != context.Canceled {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	f.CalledTimes(t, 3)
+	f.MaxConcurrent(t, 3)
+	if f.Concurrency() != 3 {
+		t.Fatal("expected 3 concurrent calls, got", f.Concurrency())
+	}
+}
+
+func TestFakeRunnerDelay(t *testing.T) {
+	h := NewHarness(time.Now())
+	f := NewFakeRunnerWith(WithClock(context.Background(), h.Clock), Delay(time.Hour, nil))
+
+	if err := h.Run(f); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	c := f.Calls()[0]
+	if dur := c.End.Sub(c.Start); dur != time.Hour {
+		t.Fatal("unexpected duration:", dur)
+	}
+}
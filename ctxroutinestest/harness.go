@@ -0,0 +1,232 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package ctxroutinestest provides helpers to test code built on ctxroutines
+package ctxroutinestest
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/raohwork/ctxroutines"
+	"github.com/raohwork/ctxroutines/fakeclock"
+)
+
+// ErrDeadlock is returned by Harness if every tracked goroutine is blocked and
+// there's no timer to fire
+type ErrDeadlock struct {
+	// stack traces of every goroutine when the deadlock is detected
+	Stack []byte
+}
+
+func (e ErrDeadlock) Error() string {
+	return "deadlock: every goroutine is blocked without pending timer"
+}
+
+// Harness runs a Runner in virtual time
+//
+// Time-based Runners in the tree must use Clock, by wrapping inner Runners with
+// ctxroutines.UseClock. Harness advances Clock to next timer once every
+// goroutine started by the Runner is blocked, so a schedule spanning hours
+// completes in milliseconds:
+//
+//     h := NewHarness(time.Now())
+//     r := Loop(RunAtLeast(time.Minute, UseClock(f, h.Clock)))
+//     err := h.RunFor(r, time.Hour) // runs f 60 times
+//
+// Goroutines waiting for real time, like time.Sleep, are treated as busy, and
+// goroutines waiting for anything outside the Runner, like other goroutines of
+// the test, are treated as blocked.
+type Harness struct {
+	Clock *fakeclock.Clock
+	// interval to check goroutines, defaults to 100 microseconds
+	Poll time.Duration
+}
+
+// NewHarness creates a Harness with virtual time starting at start
+func NewHarness(start time.Time) *Harness {
+	return &Harness{
+		Clock: fakeclock.New(start),
+		Poll:  100 * time.Microsecond,
+	}
+}
+
+// Run runs r until it returns
+//
+// It returns what r returns, or ErrDeadlock if r will never return. r is
+// canceled when deadlock is detected.
+func (h *Harness) Run(r ctxroutines.Runner) error {
+	return h.run(r, 0)
+}
+
+// RunFor is like Run, but cancels r after d in virtual time
+func (h *Harness) RunFor(r ctxroutines.Runner, d time.Duration) error {
+	if d <= 0 {
+		return h.Run(r)
+	}
+	return h.run(r, d)
+}
+
+func (h *Harness) run(r ctxroutines.Runner, limit time.Duration) error {
+	var deadline time.Time
+	if limit > 0 {
+		deadline = h.Clock.Now().Add(limit)
+	}
+
+	ids := make(chan int64, 1)
+	done := make(chan error, 1)
+	go func() {
+		ids <- currentID()
+		done <- r.Run()
+	}()
+	tracker := &tracker{ids: map[int64]bool{<-ids: true}}
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(h.Poll):
+		}
+
+		if !tracker.idle() {
+			continue
+		}
+		// make sure it's not a transient state
+		runtime.Gosched()
+		if !tracker.idle() {
+			continue
+		}
+
+		when, ok := h.Clock.Next()
+		if !deadline.IsZero() && (!ok || !when.Before(deadline)) {
+			// cancel before advancing, so timers due at deadline cannot
+			// start another run
+			r.Cancel()
+			h.Clock.Advance(deadline.Sub(h.Clock.Now()))
+			deadline = time.Time{}
+			continue
+		}
+		if !ok {
+			select {
+			case err := <-done:
+				return err
+			default:
+			}
+			err := ErrDeadlock{Stack: allStacks()}
+			r.Cancel()
+			return err
+		}
+		h.Clock.Advance(when.Sub(h.Clock.Now()))
+	}
+}
+
+func allStacks() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// currentID returns id of current goroutine
+func currentID() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	g, _ := parseHeader(string(bytes.SplitN(buf, []byte("\n"), 2)[0]))
+	return g.id
+}
+
+type goroutine struct {
+	id     int64
+	parent int64
+	state  string
+}
+
+// parseHeader parses "goroutine 7 [chan receive, 2 minutes]:"
+func parseHeader(line string) (ret goroutine, ok bool) {
+	if !strings.HasPrefix(line, "goroutine ") {
+		return
+	}
+	fields := strings.Fields(line)
+	id, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return
+	}
+	begin, end := strings.Index(line, "["), strings.LastIndex(line, "]")
+	if begin < 0 || end < begin {
+		return
+	}
+	state := line[begin+1 : end]
+	if idx := strings.Index(state, ","); idx >= 0 {
+		state = state[:idx]
+	}
+	return goroutine{id: id, state: state}, true
+}
+
+// parseStacks parses output of runtime.Stack
+//
+// Parent is found in "created by ... in goroutine N", which is supported since
+// Go 1.21.
+func parseStacks(buf []byte) (ret []goroutine) {
+	for _, block := range strings.Split(string(buf), "\n\n") {
+		lines := strings.Split(block, "\n")
+		g, ok := parseHeader(lines[0])
+		if !ok {
+			continue
+		}
+		for _, l := range lines[1:] {
+			if !strings.HasPrefix(l, "created by ") {
+				continue
+			}
+			if idx := strings.LastIndex(l, " in goroutine "); idx >= 0 {
+				g.parent, _ = strconv.ParseInt(l[idx+len(" in goroutine "):], 10, 64)
+			}
+		}
+		ret = append(ret, g)
+	}
+	return
+}
+
+// isBlocked reports if a goroutine in state can make progress only if other
+// goroutine or timer wakes it
+func isBlocked(state string) bool {
+	switch state {
+	case "running", "runnable", "syscall", "sleep", "IO wait", "preempted":
+		return false
+	}
+	return true
+}
+
+// tracker tracks a goroutine and every goroutine started by it
+type tracker struct {
+	// goroutine ids are never reused, so finished ones can be kept
+	ids map[int64]bool
+}
+
+// idle reports if every tracked goroutine is blocked
+func (t *tracker) idle() bool {
+	gs := parseStacks(allStacks())
+	for changed := true; changed; {
+		changed = false
+		for _, g := range gs {
+			if !t.ids[g.id] && t.ids[g.parent] {
+				t.ids[g.id] = true
+				changed = true
+			}
+		}
+	}
+
+	for _, g := range gs {
+		if t.ids[g.id] && !isBlocked(g.state) {
+			return false
+		}
+	}
+	return true
+}
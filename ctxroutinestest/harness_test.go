@@ -0,0 +1,104 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutinestest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/raohwork/ctxroutines"
+)
+
+func TestParseStacks(t *testing.T) {
+	dump := `goroutine 7 [chan receive, 2 minutes]:
+main.f()
+	/tmp/main.go:10 +0x1d
+created by main.main in goroutine 1
+	/tmp/main.go:20 +0x1d
+
+goroutine 1 [running]:
+main.main()
+	/tmp/main.go:21 +0x1d`
+
+	gs := parseStacks([]byte(dump))
+	if len(gs) != 2 {
+		t.Fatal("unexpected result:", gs)
+	}
+	if gs[0] != (goroutine{id: 7, parent: 1, state: "chan receive"}) {
+		t.Fatalf("unexpected 1st goroutine: %+v", gs[0])
+	}
+	if gs[1] != (goroutine{id: 1, state: "running"}) {
+		t.Fatalf("unexpected 2nd goroutine: %+v", gs[1])
+	}
+}
+
+func TestHarnessLoop(t *testing.T) {
+	h := NewHarness(time.Now())
+	var cnt int64
+	f := UseClock(CTXRunner(func(ctx context.Context) error {
+		atomic.AddInt64(&cnt, 1)
+		return nil
+	}), h.Clock)
+	other := UseClock(CTXRunner(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), h.Clock)
+
+	begin := time.Now()
+	err := h.RunFor(Skip(Loop(RunAtLeast(time.Minute, f)), other), time.Hour)
+	if err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+	if cnt != 60 {
+		t.Fatal("expected 60 runs, got", cnt)
+	}
+	if dur := time.Since(begin); dur > 10*time.Second {
+		t.Fatal("took too long in real time:", dur)
+	}
+}
+
+func TestHarnessRatelimit(t *testing.T) {
+	h := NewHarness(time.Now())
+	start := h.Clock.Now()
+	r := NoLessThan(time.Second, UseClock(NoCancelRunner(func() error { return nil }), h.Clock))
+
+	err := h.Run(NoCancelRunner(func() error {
+		for i := 0; i < 10; i++ {
+			if err := r.Run(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if dur := h.Clock.Now().Sub(start); dur != 9*time.Second {
+		t.Fatal("unexpected virtual time:", dur)
+	}
+}
+
+func TestHarnessDeadlock(t *testing.T) {
+	h := NewHarness(time.Now())
+	r := CTXRunner(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	err := h.Run(r)
+	var e ErrDeadlock
+	if !errors.As(err, &e) {
+		t.Fatal("expected deadlock, got", err)
+	}
+	if len(e.Stack) == 0 {
+		t.Fatal("expected stack traces")
+	}
+	if !IsCanceled(r) {
+		t.Fatal("expected r to be canceled")
+	}
+}
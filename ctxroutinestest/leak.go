@@ -0,0 +1,58 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutinestest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raohwork/ctxroutines"
+)
+
+// LeakTimeout is how long RunNoLeak waits for goroutines to return after the
+// Runner returns
+var LeakTimeout = time.Second
+
+// RunNoLeak runs r with ctxroutines.TrackGoroutines enabled, and fails the
+// test if any goroutine spawned by ctxroutines is still running after r
+// returns
+//
+//     err := ctxroutinestest.RunNoLeak(t, Skip(r1, WithTimeout(time.Second, r2)))
+//
+// It returns what r returns. Since tracking is global, tests using it should
+// not run in parallel with other tests spawning goroutines by ctxroutines.
+func RunNoLeak(t testing.TB, r ctxroutines.Runner) error {
+	t.Helper()
+	ctxroutines.TrackGoroutines(true)
+	defer ctxroutines.TrackGoroutines(false)
+
+	begin := time.Now()
+	err := r.Run()
+
+	var leaked []ctxroutines.GoroutineInfo
+	deadline := time.Now().Add(LeakTimeout)
+	for {
+		leaked = leaked[:0]
+		for _, g := range ctxroutines.LiveGoroutines() {
+			if !g.Created.Before(begin) {
+				leaked = append(leaked, g)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if len(leaked) > 0 {
+		msg := make([]string, len(leaked))
+		for idx, g := range leaked {
+			msg[idx] = "goroutine spawned by " + g.Owner + " at:\n" + string(g.Stack)
+		}
+		t.Errorf("%d goroutines leaked:\n%s", len(leaked), strings.Join(msg, "\n"))
+	}
+	return err
+}
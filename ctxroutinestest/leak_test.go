@@ -0,0 +1,53 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutinestest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/raohwork/ctxroutines"
+)
+
+// recorder records errors instead of failing the test
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper() {}
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRunNoLeak(t *testing.T) {
+	r := Skip(CTXRunner(block), CTXRunner(func(ctx context.Context) error { return nil }))
+	if err := RunNoLeak(t, r); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestRunNoLeakFailed(t *testing.T) {
+	defer func(d time.Duration) { LeakTimeout = d }(LeakTimeout)
+	LeakTimeout = 10 * time.Millisecond
+
+	wait := make(chan struct{})
+	defer close(wait)
+	rec := &recorder{TB: t}
+	r := WithTimeout(time.Millisecond, NoCancelRunner(func() error {
+		<-wait
+		return nil
+	}))
+
+	if err := RunNoLeak(rec, r); err != ErrRunTimeout {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(rec.errs) != 1 || !strings.Contains(rec.errs[0], "spawned by WithTimeout") {
+		t.Fatal("expected leak to be reported, got", rec.errs)
+	}
+}
@@ -0,0 +1,160 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import "time"
+
+// TriggerRunner is a Runner that runs another Runner when triggered
+//
+// Run() handles triggers until canceled, like Loop. Errors returned by wrapped
+// Runner are ignored, use WithPostRun if you need them.
+type TriggerRunner interface {
+	Runner
+	// Trigger requests a run, it never blocks
+	Trigger()
+}
+
+type triggerRunner struct {
+	ch   chan struct{}
+	loop func(t *triggerRunner) error
+	Runner
+}
+
+func (r *triggerRunner) Trigger() {
+	select {
+	case r.ch <- struct{}{}:
+	default:
+	}
+}
+
+func (r *triggerRunner) Run() error {
+	if err := r.Context().Err(); err != nil {
+		return err
+	}
+	return r.loop(r)
+}
+
+// wait waits first trigger, returns false if canceled
+func (r *triggerRunner) wait() bool {
+	select {
+	case <-r.ch:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func newTriggerRunner(r Runner, loop func(*triggerRunner) error) TriggerRunner {
+	return &triggerRunner{
+		ch:     make(chan struct{}, 1),
+		loop:   loop,
+		Runner: r,
+	}
+}
+
+// Debounce creates a TriggerRunner that runs r once no trigger comes in dur
+//
+// Say you have a Runner r rebuilding cache:
+//
+//     d := Debounce(time.Second, r)
+//     go d.Run()
+//     d.Trigger() // r runs 1 second later
+//     d.Trigger() // if it's triggered again within 1s, r runs 1 second later from now
+//
+// It is guaranteed that r runs after last trigger, unless canceled.
+func Debounce(dur time.Duration, r Runner) TriggerRunner {
+	return newTriggerRunner(r, func(t *triggerRunner) error {
+		// stopped until triggered
+		timer := clockOf(t).NewTimer(dur)
+		timer.Stop()
+		defer timer.Stop()
+
+		for t.wait() {
+			resetTimer(timer, dur)
+		quiet:
+			for {
+				select {
+				case <-t.ch:
+					resetTimer(timer, dur)
+				case <-timer.C():
+					break quiet
+				case <-t.Context().Done():
+					return t.Context().Err()
+				}
+			}
+
+			runChild(t.Runner)
+		}
+
+		return t.Context().Err()
+	})
+}
+
+// resetTimer stops, drains and resets t
+func resetTimer(t Timer, dur time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C():
+		default:
+		}
+	}
+	t.Reset(dur)
+}
+
+// Throttle creates a TriggerRunner that runs r at most once within dur
+//
+// If leading is true, r runs immediately at first trigger. If trailing is true,
+// r runs at the end of dur if triggered during the period, so it is
+// guaranteed that r runs after last trigger. Triggers during the period are
+// dropped if trailing is false. It panics if both leading and trailing are
+// false, since r would never run.
+//
+//     t := Throttle(time.Second, r, true, true)
+//     go t.Run()
+//     t.Trigger() // r runs immediately
+//     t.Trigger() // r runs 1 second later
+//     t.Trigger() // merged with previous trigger
+func Throttle(dur time.Duration, r Runner, leading, trailing bool) TriggerRunner {
+	if !leading && !trailing {
+		panic("neither leading nor trailing for Throttle")
+	}
+	return newTriggerRunner(r, func(t *triggerRunner) error {
+		// stopped until triggered
+		timer := clockOf(t).NewTimer(dur)
+		timer.Stop()
+		defer timer.Stop()
+
+		for t.wait() {
+			pending := true
+			if leading {
+				runChild(t.Runner)
+				pending = false
+			}
+
+			for {
+				resetTimer(timer, dur)
+			period:
+				for {
+					select {
+					case <-t.ch:
+						pending = true
+					case <-timer.C():
+						break period
+					case <-t.Context().Done():
+						return t.Context().Err()
+					}
+				}
+
+				if !trailing || !pending {
+					break
+				}
+				runChild(t.Runner)
+				pending = false
+			}
+		}
+
+		return t.Context().Err()
+	})
+}
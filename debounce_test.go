@@ -0,0 +1,87 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func countingRunner(cnt *int64) Runner {
+	return CTXRunner(func(context.Context) error {
+		atomic.AddInt64(cnt, 1)
+		return nil
+	})
+}
+
+func startTrigger(t *testing.T, r TriggerRunner) (stop func()) {
+	done := make(chan error)
+	go func() { done <- r.Run() }()
+	return func() {
+		r.Cancel()
+		if err := <-done; err != context.Canceled {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	var cnt int64
+	r := Debounce(20*time.Millisecond, countingRunner(&cnt))
+	stop := startTrigger(t, r)
+	defer stop()
+
+	for i := 0; i < 5; i++ {
+		r.Trigger()
+		time.Sleep(5 * time.Millisecond)
+	}
+	if v := atomic.LoadInt64(&cnt); v != 0 {
+		t.Fatalf("expected not run yet, got %d runs", v)
+	}
+	waitFor(t, "debounced run", func() bool { return atomic.LoadInt64(&cnt) == 1 })
+
+	r.Trigger()
+	waitFor(t, "second run", func() bool { return atomic.LoadInt64(&cnt) == 2 })
+}
+
+func testThrottle(leading, trailing bool, expectFirst, expectFinal int64) func(*testing.T) {
+	return func(t *testing.T) {
+		var cnt int64
+		r := Throttle(30*time.Millisecond, countingRunner(&cnt), leading, trailing)
+		stop := startTrigger(t, r)
+		defer stop()
+
+		r.Trigger()
+		time.Sleep(5 * time.Millisecond)
+		if v := atomic.LoadInt64(&cnt); v != expectFirst {
+			t.Fatalf("expected %d runs after first trigger, got %d", expectFirst, v)
+		}
+		for i := 0; i < 3; i++ {
+			r.Trigger()
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		if v := atomic.LoadInt64(&cnt); v != expectFinal {
+			t.Fatalf("expected %d runs in total, got %d", expectFinal, v)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	t.Run("leading", testThrottle(true, false, 1, 1))
+	t.Run("trailing", testThrottle(false, true, 0, 1))
+	t.Run("both", testThrottle(true, true, 1, 2))
+	t.Run("neither", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected to panic")
+			}
+		}()
+		Throttle(time.Second, NoCancelRunner(func() error { return nil }), false, false)
+	})
+}
@@ -25,16 +25,24 @@ func Run(rs ...Runner) (err []error) {
 	wg.Add(l)
 	err = make([]error, l)
 	for idx, r := range rs {
-		go func(idx int, r Runner) {
-			err[idx] = r.Run()
+		idx, r := idx, r
+		spawn("Run", func() {
+			err[idx] = runChild(r)
 			wg.Done()
-		}(idx, r)
+		})
 	}
 
 	wg.Wait()
 	return
 }
 
+// RunAll is like Run, but returns a MultiError containing every non-nil error
+//
+// It returns nil if every Runner of rs returns nil.
+func RunAll(rs ...Runner) (err error) {
+	return newMultiError(rs, Run(rs...))
+}
+
 // FirstErr creates a Runner that runs every Runner of rs in order, until first error occured
 func FirstErr(rs ...Runner) (ret Runner) {
 	return FuncRunner(CancelAll(rs...), func() (err error) {
@@ -77,14 +85,17 @@ func SomeErr(rs ...Runner) (ret Runner) {
 }
 
 // AnyErr creates a Runner that returns first known error.
+//
+// See FirstSuccess if you want first success instead.
 func AnyErr(rs ...Runner) (ret Runner) {
 	return FuncRunner(CancelAll(rs...), func() (err error) {
 		ch := make(chan error, 1)
 
 		for _, r := range rs {
-			go func(r Runner) {
-				ch <- r.Run()
-			}(r)
+			r := r
+			spawn("AnyErr", func() {
+				ch <- runChild(r)
+			})
 		}
 
 		for range rs {
@@ -98,3 +109,13 @@ func AnyErr(rs ...Runner) (ret Runner) {
 		return
 	})
 }
+
+// AllErrors creates a Runner that runs every Runner of rs in separated goroutine,
+// and returns a MultiError containing every non-nil error
+//
+// Use Named() to give the Runners names, which are recorded in ChildError.
+func AllErrors(rs ...Runner) (ret Runner) {
+	return FuncRunner(CancelAll(rs...), func() error {
+		return RunAll(rs...)
+	})
+}
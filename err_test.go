@@ -167,3 +167,35 @@ func TestSomeErr(t *testing.T) {
 	t.Run("mixed2", testSomeErr(e, []error{f, e, nil}))
 	t.Run("mixed3", testSomeErr(e, []error{nil, f, e}))
 }
+
+func TestAllErrors(t *testing.T) {
+	e1 := errors.New("1")
+	e2 := &ErrSignalReceived{}
+	err := AllErrors(
+		NoCancelRunner(func() error { return e1 }),
+		NoCancelRunner(func() error { return nil }),
+		Named("sig", NoCancelRunner(func() error { return e2 })),
+	).Run()
+
+	var m MultiError
+	if !errors.As(err, &m) {
+		t.Fatal("unexpected error:", err)
+	}
+	if l := len(m); l != 2 {
+		t.Fatalf("expected 2 errors, got %d", l)
+	}
+	if m[0].Index != 0 || m[1].Index != 2 || m[1].Name != "sig" {
+		t.Fatalf("unexpected child errors: %+v", m)
+	}
+	if !errors.Is(err, e1) {
+		t.Error("expected to match e1")
+	}
+	var x *ErrSignalReceived
+	if !errors.As(err, &x) || x != e2 {
+		t.Error("expected to match e2")
+	}
+
+	if err = RunAll(NoCancelRunner(func() error { return nil })); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
@@ -0,0 +1,122 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import "time"
+
+// FixedRate runs at fixed interval regardless how long a run takes, which is
+// default mode of Every
+//
+// Use WithOverlap to decide what to do if a run overruns its slot.
+func FixedRate() TickOption {
+	return func(c *tickConfig) { c.fixedDelay = false }
+}
+
+// FixedDelay waits fixed interval between end of a run and start of next run
+func FixedDelay() TickOption {
+	return func(c *tickConfig) { c.fixedDelay = true }
+}
+
+// WithSplay delays first run by random duration in [0, d), so replicas started
+// at same time do not run at same time
+func WithSplay(d time.Duration) TickOption {
+	return func(c *tickConfig) { c.splay = d }
+}
+
+// WithAlignment aligns runs to multiples of d since zero time, like on the
+// minute with time.Minute
+//
+// Since zero time is in UTC, aligning to time.Hour or longer might not be what
+// you expect in timezones with non-hour offset. Use Schedule in that case.
+func WithAlignment(d time.Duration) TickOption {
+	return func(c *tickConfig) { c.align = d }
+}
+
+// firstTick returns time of first run according to alignment and splay
+func (c *tickConfig) firstTick(now time.Time) (ret time.Time) {
+	ret = now
+	if c.align > 0 {
+		if ret = now.Truncate(c.align); ret.Before(now) {
+			ret = ret.Add(c.align)
+		}
+	}
+	return ret.Add(time.Duration(c.rand.Int63n(int64(c.splay))))
+}
+
+type fixedDelayRunner struct {
+	interval time.Duration
+	cfg      *tickConfig
+	Runner
+}
+
+func (r *fixedDelayRunner) Run() (err error) {
+	if err = r.Context().Err(); err != nil {
+		return
+	}
+
+	now := clockOf(r).Now()
+	wait := r.cfg.firstTick(now).Sub(now)
+	for !sleep(r.Context(), wait) {
+		if err = runChild(r.Runner); err != nil {
+			r.cfg.onError(err)
+		}
+		wait = r.interval + r.cfg.jitter()
+	}
+
+	return r.Context().Err()
+}
+
+// Every creates a Runner that runs r every interval
+//
+// It runs in FixedRate mode by default: r runs at start, start+interval,
+// start+2*interval... Missed runs are dropped unless WithCatchUp is
+// specified. In FixedDelay mode, it waits interval after r returns.
+//
+// Start time can be adjusted by WithAlignment and WithSplay, and WithJitter
+// adds random delay to every run. Say you want to run r every minute near
+// 30th second:
+//
+//     r := Every(time.Minute, r,
+//         WithAlignment(time.Minute),
+//         WithSplay(30*time.Second),
+//         WithJitter(time.Second),
+//     )
+//
+// Run() blocks until canceled like Loop, and waits running r to return before
+// returning. Errors returned by r are ignored unless WithErrorHandler is
+// specified.
+//
+// It panics if interval is not positive, like time.NewTicker.
+//
+// You have to call Cancel() to release resources.
+func Every(interval time.Duration, r Runner, opts ...TickOption) (ret Runner) {
+	if interval <= 0 {
+		panic("non-positive interval for Every")
+	}
+	cfg := newTickConfig(opts)
+	if cfg.fixedDelay {
+		return &fixedDelayRunner{
+			interval: interval,
+			cfg:      cfg,
+			Runner:   r,
+		}
+	}
+
+	return &tickRunner{
+		plan: func(now time.Time) (time.Time, func(time.Time) time.Time) {
+			anchor := cfg.firstTick(now)
+			return anchor, func(t time.Time) time.Time {
+				if t.Before(anchor) {
+					return anchor
+				}
+				n := t.Sub(anchor)/interval + 1
+				return anchor.Add(n * interval)
+			}
+		},
+		cfg:    cfg,
+		owner:  "Every",
+		Runner: r,
+	}
+}
@@ -0,0 +1,89 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func collectTicks(t *testing.T, n int, interval time.Duration, cost time.Duration, opts ...TickOption) (ret []time.Duration) {
+	ch := make(chan time.Time, n)
+	var r Runner
+	r = Every(interval, CTXRunner(func(c context.Context) error {
+		ch <- time.Now()
+		if len(ch) == n {
+			r.Cancel()
+		}
+		sleep(c, cost)
+		return nil
+	}), opts...)
+
+	begin := time.Now()
+	if err := r.Run(); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+	close(ch)
+	for x := range ch {
+		ret = append(ret, x.Sub(begin))
+	}
+	return
+}
+
+func checkTicks(t *testing.T, actual []time.Duration, expect ...time.Duration) {
+	t.Helper()
+	if len(actual) != len(expect) {
+		t.Fatalf("expected %d runs, got %v", len(expect), actual)
+	}
+	for idx, e := range expect {
+		if d := actual[idx] - e; d < 0 || d > 15*time.Millisecond {
+			t.Fatalf("expected run #%d at %v, got %v", idx, e, actual[idx])
+		}
+	}
+}
+
+func TestEveryFixedRate(t *testing.T) {
+	const ms = 3 * time.Millisecond
+	checkTicks(t, collectTicks(t, 3, 10*ms, 0), 0, 10*ms, 20*ms)
+	// 2nd run at 10ms is skipped
+	checkTicks(t, collectTicks(t, 2, 10*ms, 15*ms), 0, 20*ms)
+	checkTicks(t, collectTicks(t, 3, 10*ms, 15*ms, WithOverlap(OverlapAllow)), 0, 10*ms, 20*ms)
+	checkTicks(t, collectTicks(t, 3, 10*ms, 15*ms, WithOverlap(OverlapQueue)), 0, 15*ms, 30*ms)
+}
+
+func TestEveryFixedDelay(t *testing.T) {
+	const ms = 3 * time.Millisecond
+	checkTicks(t, collectTicks(t, 3, 10*ms, 5*ms, FixedDelay()), 0, 15*ms, 30*ms)
+}
+
+func TestEveryJitter(t *testing.T) {
+	const ms = 3 * time.Millisecond
+	ticks := collectTicks(t, 3, 20*ms, 0, WithSplay(10*ms), WithJitter(5*ms))
+	if len(ticks) != 3 {
+		t.Fatal("expected 3 runs, got", ticks)
+	}
+	for idx, d := range ticks {
+		min := time.Duration(idx) * 20 * ms
+		if d < min || d > min+20*ms {
+			t.Fatalf("run #%d out of range: %v", idx, d)
+		}
+	}
+}
+
+func TestEveryAlignment(t *testing.T) {
+	ch := make(chan time.Time, 1)
+	r := Every(time.Hour, CTXRunner(func(context.Context) error {
+		ch <- time.Now()
+		return nil
+	}), WithAlignment(50*time.Millisecond))
+	defer r.Cancel()
+	go r.Run()
+
+	now := <-ch
+	if d := now.Sub(now.Truncate(50 * time.Millisecond)); d > 15*time.Millisecond {
+		t.Fatal("expected to run at multiple of 50ms, got", now)
+	}
+}
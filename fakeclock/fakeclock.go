@@ -0,0 +1,156 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package fakeclock provides a ctxroutines.Clock which moves only when told
+//
+//     clk := fakeclock.New(time.Now())
+//     r := ctxroutines.RunAtLeast(10*time.Second, ctxroutines.UseClock(f, clk))
+//     go r.Run()
+//     clk.BlockUntil(1)           // wait until r.Run() is waiting for the clock
+//     clk.Advance(10*time.Second) // r.Run() returns immediately
+package fakeclock
+
+import (
+	"sync"
+	"time"
+
+	"github.com/raohwork/ctxroutines"
+)
+
+// Clock is a ctxroutines.Clock which moves only when Advance is called
+type Clock struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers map[*timer]struct{}
+}
+
+// New creates a Clock starting at now
+func New(now time.Time) (ret *Clock) {
+	ret = &Clock{
+		now:    now,
+		timers: map[*timer]struct{}{},
+	}
+	ret.cond = sync.NewCond(&ret.lock)
+	return
+}
+
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *Clock) NewTimer(d time.Duration) ctxroutines.Timer {
+	t := &timer{clock: c, ch: make(chan time.Time, 1)}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.schedule(t, d)
+	return t
+}
+
+// schedule MUST be called with lock held
+func (c *Clock) schedule(t *timer, d time.Duration) {
+	if d <= 0 {
+		t.fire(c.now)
+		return
+	}
+	t.when = c.now.Add(d)
+	c.timers[t] = struct{}{}
+	c.cond.Broadcast()
+}
+
+// Advance moves the clock forward by d, and fires timers in order
+func (c *Clock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		var next *timer
+		for t := range c.timers {
+			if !t.when.After(end) && (next == nil || t.when.Before(next.when)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		if next.when.After(c.now) {
+			c.now = next.when
+		}
+		delete(c.timers, next)
+		next.fire(c.now)
+	}
+	c.now = end
+	c.cond.Broadcast()
+}
+
+// Next returns when the earliest active timer fires, ok is false if there's
+// no active timer
+func (c *Clock) Next() (when time.Time, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for t := range c.timers {
+		if !ok || t.when.Before(when) {
+			when, ok = t.when, true
+		}
+	}
+	return
+}
+
+// Waiters returns number of active timers
+func (c *Clock) Waiters() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until there are at least n active timers
+//
+// It's useful to ensure the code under test is waiting for the clock before
+// calling Advance.
+func (c *Clock) BlockUntil(n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+type timer struct {
+	clock *Clock
+	ch    chan time.Time
+	when  time.Time
+}
+
+// fire MUST be called with lock held
+func (t *timer) fire(now time.Time) {
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
+
+func (t *timer) C() <-chan time.Time { return t.ch }
+
+func (t *timer) Stop() bool {
+	c := t.clock
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, ok := c.timers[t]
+	delete(c.timers, t)
+	c.cond.Broadcast()
+	return ok
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, ok := c.timers[t]
+	delete(c.timers, t)
+	c.schedule(t, d)
+	return ok
+}
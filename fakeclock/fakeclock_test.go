@@ -0,0 +1,85 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fakeclock
+
+import (
+	"testing"
+	"time"
+)
+
+func fired(ch <-chan time.Time) (ret time.Time, ok bool) {
+	select {
+	case ret = <-ch:
+		return ret, true
+	default:
+		return
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := New(start)
+	t1 := c.NewTimer(time.Second)
+	t2 := c.NewTimer(2 * time.Second)
+
+	c.Advance(999 * time.Millisecond)
+	if _, ok := fired(t1.C()); ok {
+		t.Fatal("timer fired too early")
+	}
+
+	c.Advance(time.Second)
+	if v, ok := fired(t1.C()); !ok || !v.Equal(start.Add(time.Second)) {
+		t.Fatal("unexpected result of 1st timer:", v, ok)
+	}
+	if _, ok := fired(t2.C()); ok {
+		t.Fatal("2nd timer fired too early")
+	}
+	if now := c.Now(); !now.Equal(start.Add(1999 * time.Millisecond)) {
+		t.Fatal("unexpected time:", now)
+	}
+	if c.Waiters() != 1 {
+		t.Fatal("expected 1 waiter, got", c.Waiters())
+	}
+
+	if !t2.Stop() {
+		t.Fatal("expected active timer to be stopped")
+	}
+	c.Advance(time.Second)
+	if _, ok := fired(t2.C()); ok {
+		t.Fatal("stopped timer fired")
+	}
+
+	if t2.Reset(time.Second) {
+		t.Fatal("expected Reset to report inactive timer")
+	}
+	c.Advance(time.Second)
+	if _, ok := fired(t2.C()); !ok {
+		t.Fatal("reset timer should fire")
+	}
+
+	t3 := c.NewTimer(0)
+	if _, ok := fired(t3.C()); !ok {
+		t.Fatal("timer with zero duration should fire immediately")
+	}
+}
+
+func TestBlockUntil(t *testing.T) {
+	c := New(time.Now())
+	done := make(chan struct{})
+	go func() {
+		c.BlockUntil(2)
+		close(done)
+	}()
+
+	c.NewTimer(time.Second)
+	select {
+	case <-done:
+		t.Fatal("BlockUntil returned too early")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	c.NewTimer(time.Second)
+	<-done
+}
@@ -0,0 +1,90 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import "context"
+
+// FirstSuccess creates a Runner that runs every Runner of rs in separated
+// goroutine, and returns nil once any of them succeeds
+//
+// Others are stopped after first success, as described in ContextRunner.
+// Errors are ignored until every Runner of rs fails, a MultiError containing
+// all errors is returned in that case.
+//
+// You have to call Cancel() to release resources.
+func FirstSuccess(rs ...Runner) (ret Runner) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := CancelAll(rs...)
+	return NewRunner(ctx, func() { cancel(); c() }, func() (err error) {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		runCtx, stop := context.WithCancel(ctx)
+		defer stop()
+
+		type result struct {
+			idx int
+			err error
+		}
+		ch := make(chan result, len(rs))
+		for idx, r := range rs {
+			idx, r := idx, r
+			spawn("FirstSuccess", func() {
+				ch <- result{idx: idx, err: runChild(FromRunner(r, func() error {
+					return runCancelable("FirstSuccess", runCtx, r)
+				}))}
+			})
+		}
+
+		errs := make([]error, len(rs))
+		succeeded := false
+		for range rs {
+			res := <-ch
+			if res.err == nil {
+				succeeded = true
+				stop()
+			}
+			errs[res.idx] = res.err
+		}
+
+		if succeeded {
+			return nil
+		}
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		return newMultiError(rs, errs)
+	})
+}
+
+// Fallback creates a Runner that runs primary, and runs secondaries in order
+// until one of them succeeds
+//
+// It returns nil if any of them succeeds, or a MultiError containing every
+// error if all of them fail.
+//
+// You have to call Cancel() to release resources.
+func Fallback(primary Runner, secondaries ...Runner) (ret Runner) {
+	rs := append([]Runner{primary}, secondaries...)
+	ctx, cancel := context.WithCancel(context.Background())
+	c := CancelAll(rs...)
+	return NewRunner(ctx, func() { cancel(); c() }, func() (err error) {
+		errs := make([]error, len(rs))
+		for idx, r := range rs {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+			if errs[idx] = runChild(r); errs[idx] == nil {
+				return nil
+			}
+		}
+
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		return newMultiError(rs, errs)
+	})
+}
@@ -0,0 +1,121 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFirstSuccess(t *testing.T) {
+	e := errors.New("")
+	slow := CTXRunner(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	r := FirstSuccess(
+		CTXRunner(func(ctx context.Context) error { return e }),
+		slow,
+		CTXRunner(func(ctx context.Context) error { return nil }),
+	)
+	defer r.Cancel()
+
+	for i := 0; i < 2; i++ {
+		if err := r.Run(); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	if IsCanceled(slow) {
+		t.Fatal("ContextRunner should be stopped without being canceled")
+	}
+}
+
+func TestFirstSuccessAllFail(t *testing.T) {
+	e1, e2 := errors.New("1"), errors.New("2")
+	r := FirstSuccess(
+		CTXRunner(func(ctx context.Context) error { return e1 }),
+		NoCancelRunner(func() error { return e2 }),
+	)
+	defer r.Cancel()
+
+	err := r.Run()
+	var m MultiError
+	if !errors.As(err, &m) || len(m) != 2 || m[0].Err != e1 || m[1].Err != e2 {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestFirstSuccessCancel(t *testing.T) {
+	start := make(chan struct{})
+	r := FirstSuccess(CTXRunner(func(ctx context.Context) error {
+		close(start)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	go func() {
+		<-start
+		r.Cancel()
+	}()
+	if err := r.Run(); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	e1, e2 := errors.New("1"), errors.New("2")
+	ran := false
+	r := Fallback(
+		NoCancelRunner(func() error { return e1 }),
+		NoCancelRunner(func() error { return nil }),
+		NoCancelRunner(func() error {
+			ran = true
+			return nil
+		}),
+	)
+	defer r.Cancel()
+
+	if err := r.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if ran {
+		t.Fatal("should stop after first success")
+	}
+
+	r = Fallback(
+		NoCancelRunner(func() error { return e1 }),
+		Named("backup", NoCancelRunner(func() error { return e2 })),
+	)
+	defer r.Cancel()
+
+	err := r.Run()
+	var m MultiError
+	if !errors.As(err, &m) || len(m) != 2 || m[1].Name != "backup" || m[1].Err != e2 {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestFallbackCancel(t *testing.T) {
+	ran := false
+	var r Runner
+	r = Fallback(
+		NoCancelRunner(func() error {
+			r.Cancel()
+			return errors.New("")
+		}),
+		NoCancelRunner(func() error {
+			ran = true
+			return nil
+		}),
+	)
+
+	if err := r.Run(); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+	if ran {
+		t.Fatal("should stop after canceled")
+	}
+}
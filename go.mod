@@ -1,5 +1,5 @@
 module github.com/raohwork/ctxroutines
 
-go 1.16
+go 1.21
 
-require golang.org/x/time v0.0.0-20210723032227-1f47c861a9ac // indirect
+require golang.org/x/time v0.0.0-20210723032227-1f47c861a9ac
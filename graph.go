@@ -0,0 +1,276 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrSkipped is recorded for nodes of a Graph that are not run because a
+// dependency failed
+var ErrSkipped = errors.New("skipped due to failed dependency")
+
+// CycleError is returned by Graph.Build if there's a dependency cycle
+type CycleError struct {
+	// names of nodes in the cycle, first node is repeated at the end
+	Nodes []string
+}
+
+func (e CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(e.Nodes, " -> ")
+}
+
+// FailurePolicy decides what a Graph does when a node fails
+type FailurePolicy int
+
+const (
+	// SkipDependents skips nodes depending on the failed node, independent
+	// branches keep running
+	SkipDependents FailurePolicy = iota
+	// StopAll stops running nodes and skips every pending node
+	StopAll
+)
+
+type graphNode struct {
+	name string
+	r    Runner
+	deps []string
+}
+
+// Graph builds a Runner that runs Runners in order of their dependencies
+//
+//     g := NewGraph()
+//     g.Add("migrate", migrate)
+//     g.Add("seed", seed, "migrate")
+//     g.Add("warmup", warmup, "seed")
+//     g.Add("indexer", indexer, "seed")
+//     r, err := g.Build(2, SkipDependents)
+//
+// A node runs after every dependency returns nil, and nodes without pending
+// dependencies run in parallel.
+type Graph struct {
+	nodes []graphNode
+}
+
+// NewGraph creates an empty Graph
+func NewGraph() *Graph {
+	return &Graph{}
+}
+
+// Add adds a node named name, which runs r after every node in deps succeeds
+//
+// Nodes can be added in any order, dependencies are resolved in Build.
+func (g *Graph) Add(name string, r Runner, deps ...string) {
+	g.nodes = append(g.nodes, graphNode{name: name, r: r, deps: deps})
+}
+
+// Build validates the Graph and creates a Runner that runs it
+//
+// At most concurrency nodes run at same time, 0 means no limit. It returns an
+// error if names are duplicated, dependencies are missing or there's a cycle
+// (a CycleError).
+//
+// Run() of the returned Runner returns nil if every node succeeds. Otherwise
+// it returns a MultiError, ChildError.Index is the order nodes were added and
+// ChildError.Name is the name of node. Skipped nodes are recorded with
+// ErrSkipped.
+//
+// See ContextRunner for how nodes are stopped.
+//
+// You have to call Cancel() to release resources.
+func (g *Graph) Build(concurrency int, policy FailurePolicy) (ret Runner, err error) {
+	index := make(map[string]int, len(g.nodes))
+	for idx, n := range g.nodes {
+		if _, ok := index[n.name]; ok {
+			return nil, errors.New("duplicated node: " + n.name)
+		}
+		index[n.name] = idx
+	}
+
+	deps := make([][]int, len(g.nodes))
+	dependents := make([][]int, len(g.nodes))
+	for idx, n := range g.nodes {
+		for _, d := range n.deps {
+			i, ok := index[d]
+			if !ok {
+				return nil, errors.New("unknown dependency " + d + " of node " + n.name)
+			}
+			deps[idx] = append(deps[idx], i)
+			dependents[i] = append(dependents[i], idx)
+		}
+	}
+	if err = g.findCycle(deps); err != nil {
+		return
+	}
+
+	nodes := make([]graphNode, len(g.nodes))
+	copy(nodes, g.nodes)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &graphRunner{
+		nodes:       nodes,
+		deps:        deps,
+		dependents:  dependents,
+		concurrency: concurrency,
+		policy:      policy,
+		funcRunner:  funcRunner{ctx: ctx, cancel: cancel},
+	}, nil
+}
+
+// findCycle returns a CycleError if there's a cycle
+func (g *Graph) findCycle(deps [][]int) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(deps))
+	var path []int
+	var visit func(idx int) []int
+	visit = func(idx int) []int {
+		state[idx] = visiting
+		path = append(path, idx)
+		for _, d := range deps[idx] {
+			switch state[d] {
+			case visiting:
+				for i, p := range path {
+					if p == d {
+						return append(path[i:], d)
+					}
+				}
+			case unvisited:
+				if c := visit(d); c != nil {
+					return c
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[idx] = visited
+		return nil
+	}
+
+	for idx := range deps {
+		if state[idx] != unvisited {
+			continue
+		}
+		if c := visit(idx); c != nil {
+			names := make([]string, len(c))
+			// path is in dependency order, reverse it to follow execution order
+			for i, n := range c {
+				names[len(c)-1-i] = g.nodes[n].name
+			}
+			return CycleError{Nodes: names}
+		}
+	}
+	return nil
+}
+
+type graphRunner struct {
+	nodes       []graphNode
+	deps        [][]int
+	dependents  [][]int
+	concurrency int
+	policy      FailurePolicy
+	funcRunner
+}
+
+type graphResult struct {
+	idx int
+	err error
+}
+
+func (r *graphRunner) runNode(ctx context.Context, idx int) (err error) {
+	if isRecovering() {
+		defer catchPanic(&err)
+	}
+	return runCancelable("Graph", ctx, r.nodes[idx].r)
+}
+
+func (r *graphRunner) Run() (err error) {
+	if err = r.ctx.Err(); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	pending := make([]int, len(r.nodes))
+	var ready []int
+	for idx := range r.nodes {
+		if pending[idx] = len(r.deps[idx]); pending[idx] == 0 {
+			ready = append(ready, idx)
+		}
+	}
+
+	errs := make([]error, len(r.nodes))
+	skipped := make([]bool, len(r.nodes))
+	var skip func(idx int)
+	skip = func(idx int) {
+		for _, d := range r.dependents[idx] {
+			if !skipped[d] {
+				skipped[d] = true
+				errs[d] = ErrSkipped
+				skip(d)
+			}
+		}
+	}
+
+	ch := make(chan graphResult, len(r.nodes))
+	running := 0
+	for {
+		for len(ready) > 0 && ctx.Err() == nil && (r.concurrency <= 0 || running < r.concurrency) {
+			idx := ready[0]
+			ready = ready[1:]
+			running++
+			spawn("Graph", func() {
+				ch <- graphResult{idx: idx, err: r.runNode(ctx, idx)}
+			})
+		}
+		if running == 0 {
+			break
+		}
+
+		res := <-ch
+		running--
+		if res.err == nil {
+			for _, d := range r.dependents[res.idx] {
+				if pending[d]--; pending[d] == 0 && !skipped[d] {
+					ready = append(ready, d)
+				}
+			}
+			continue
+		}
+
+		errs[res.idx] = res.err
+		skip(res.idx)
+		if r.policy == StopAll {
+			cancel()
+		}
+	}
+
+	// nodes never started after stopped
+	if ctx.Err() != nil {
+		for _, idx := range ready {
+			errs[idx] = ErrSkipped
+		}
+		for idx := range r.nodes {
+			if pending[idx] > 0 && errs[idx] == nil {
+				errs[idx] = ErrSkipped
+			}
+		}
+	}
+
+	var ret MultiError
+	for idx, e := range errs {
+		if e != nil {
+			ret = append(ret, ChildError{Index: idx, Name: r.nodes[idx].name, Err: e})
+		}
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret
+}
@@ -0,0 +1,212 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGraphOrder(t *testing.T) {
+	lock := sync.Mutex{}
+	var order []string
+	var cur, max int64
+	node := func(name string) Runner {
+		return NoCancelRunner(func() error {
+			if v := atomic.AddInt64(&cur, 1); v > atomic.LoadInt64(&max) {
+				atomic.StoreInt64(&max, v)
+			}
+			defer atomic.AddInt64(&cur, -1)
+			lock.Lock()
+			defer lock.Unlock()
+			order = append(order, name)
+			return nil
+		})
+	}
+
+	g := NewGraph()
+	g.Add("warmup", node("warmup"), "seed")
+	g.Add("indexer", node("indexer"), "seed")
+	g.Add("seed", node("seed"), "migrate")
+	g.Add("migrate", node("migrate"))
+	r, err := g.Build(1, SkipDependents)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer r.Cancel()
+
+	if err = r.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(order) != 4 || order[0] != "migrate" || order[1] != "seed" {
+		t.Fatal("unexpected order:", order)
+	}
+	if max != 1 {
+		t.Fatal("expected at most 1 node running, got", max)
+	}
+}
+
+func TestGraphParallel(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(3)
+	node := NoCancelRunner(func() error {
+		wg.Done()
+		wg.Wait()
+		return nil
+	})
+
+	g := NewGraph()
+	g.Add("a", node)
+	g.Add("b", node)
+	g.Add("c", node)
+	r, err := g.Build(0, SkipDependents)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer r.Cancel()
+
+	// deadlocks if nodes are not run in parallel
+	if err = r.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestGraphBuildError(t *testing.T) {
+	nop := NoCancelRunner(func() error { return nil })
+
+	g := NewGraph()
+	g.Add("a", nop)
+	g.Add("a", nop)
+	if _, err := g.Build(0, SkipDependents); err == nil {
+		t.Fatal("expected error for duplicated node")
+	}
+
+	g = NewGraph()
+	g.Add("a", nop, "b")
+	if _, err := g.Build(0, SkipDependents); err == nil {
+		t.Fatal("expected error for unknown dependency")
+	}
+
+	g = NewGraph()
+	g.Add("root", nop)
+	g.Add("a", nop, "root", "c")
+	g.Add("b", nop, "a")
+	g.Add("c", nop, "b")
+	_, err := g.Build(0, SkipDependents)
+	var cycle CycleError
+	if !errors.As(err, &cycle) {
+		t.Fatal("expected CycleError, got", err)
+	}
+	if len(cycle.Nodes) != 4 || cycle.Nodes[0] != cycle.Nodes[3] {
+		t.Fatal("unexpected cycle:", cycle.Nodes)
+	}
+}
+
+func TestGraphSkipDependents(t *testing.T) {
+	e := errors.New("")
+	var ran int64
+	ok := NoCancelRunner(func() error {
+		atomic.AddInt64(&ran, 1)
+		return nil
+	})
+
+	g := NewGraph()
+	g.Add("migrate", NoCancelRunner(func() error { return e }))
+	g.Add("seed", ok, "migrate")
+	g.Add("warmup", ok, "seed")
+	g.Add("other", ok)
+	r, err := g.Build(0, SkipDependents)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer r.Cancel()
+
+	err = r.Run()
+	var m MultiError
+	if !errors.As(err, &m) {
+		t.Fatal("expected MultiError, got", err)
+	}
+	if len(m) != 3 {
+		t.Fatal("unexpected errors:", m)
+	}
+	if m[0].Name != "migrate" || m[0].Err != e {
+		t.Fatal("unexpected error of migrate:", m[0])
+	}
+	if m[1].Name != "seed" || m[1].Err != ErrSkipped || m[2].Name != "warmup" || m[2].Err != ErrSkipped {
+		t.Fatal("expected dependents to be skipped, got", m)
+	}
+	if ran != 1 {
+		t.Fatal("expected independent node to run, got", ran)
+	}
+}
+
+func TestGraphStopAll(t *testing.T) {
+	e := errors.New("")
+	start := make(chan struct{})
+	var ran int64
+
+	g := NewGraph()
+	g.Add("slow", CTXRunner(func(ctx context.Context) error {
+		close(start)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	g.Add("fail", NoCancelRunner(func() error {
+		<-start
+		return e
+	}))
+	g.Add("next", NoCancelRunner(func() error {
+		atomic.AddInt64(&ran, 1)
+		return nil
+	}), "fail")
+	r, err := g.Build(0, StopAll)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer r.Cancel()
+
+	err = r.Run()
+	if !errors.Is(err, e) || !errors.Is(err, context.Canceled) || !errors.Is(err, ErrSkipped) {
+		t.Fatal("unexpected error:", err)
+	}
+	if ran != 0 {
+		t.Fatal("expected dependent not to run")
+	}
+
+	// ContextRunner is not canceled, so the graph can run again
+	start = make(chan struct{})
+	if err = r.Run(); !errors.Is(err, e) {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestGraphCancel(t *testing.T) {
+	start := make(chan struct{})
+	g := NewGraph()
+	g.Add("a", CTXRunner(func(ctx context.Context) error {
+		close(start)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	g.Add("b", NoCancelRunner(func() error { return nil }), "a")
+	r, err := g.Build(0, SkipDependents)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	go func() {
+		<-start
+		r.Cancel()
+	}()
+	if err = r.Run(); !errors.Is(err, context.Canceled) {
+		t.Fatal("unexpected error:", err)
+	}
+	if err = r.Run(); err != context.Canceled {
+		t.Fatal("expected context.Canceled after canceled, got", err)
+	}
+}
@@ -0,0 +1,227 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HedgeStats records how a HedgeRunner runs
+type HedgeStats struct {
+	// number of Run() calls
+	Runs uint64
+	// number of backup Runners started
+	Fired uint64
+	// number of runs succeeded by a backup Runner
+	Won uint64
+}
+
+// HedgeRunner is a Runner created by Hedge or AdaptiveHedge
+type HedgeRunner interface {
+	Runner
+	Stats() HedgeStats
+}
+
+// hedgeWindow is number of latencies AdaptiveHedge remembers
+const hedgeWindow = 128
+
+// hedgeMinSamples is number of latencies required before AdaptiveHedge uses
+// the percentile
+const hedgeMinSamples = 10
+
+// latencies remembers latest durations of successful runs
+type latencies struct {
+	lock sync.Mutex
+	buf  [hedgeWindow]time.Duration
+	cnt  int
+}
+
+func (l *latencies) add(d time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.buf[l.cnt%hedgeWindow] = d
+	l.cnt++
+}
+
+// percentile returns p-th percentile, or false if not enough samples
+func (l *latencies) percentile(p float64) (time.Duration, bool) {
+	l.lock.Lock()
+	n := l.cnt
+	if n > hedgeWindow {
+		n = hedgeWindow
+	}
+	if n < hedgeMinSamples {
+		l.lock.Unlock()
+		return 0, false
+	}
+	buf := make([]time.Duration, n)
+	copy(buf, l.buf[:n])
+	l.lock.Unlock()
+
+	sort.Slice(buf, func(i, j int) bool { return buf[i] < buf[j] })
+	idx := int(p * float64(n))
+	if idx >= n {
+		idx = n - 1
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	return buf[idx], true
+}
+
+type hedgeRunner struct {
+	rs    []Runner
+	delay func() time.Duration
+	done  func(time.Duration)
+	runs  uint64
+	fired uint64
+	won   uint64
+	funcRunner
+}
+
+func (r *hedgeRunner) Stats() HedgeStats {
+	return HedgeStats{
+		Runs:  atomic.LoadUint64(&r.runs),
+		Fired: atomic.LoadUint64(&r.fired),
+		Won:   atomic.LoadUint64(&r.won),
+	}
+}
+
+type hedgeResult struct {
+	idx int
+	err error
+}
+
+func (r *hedgeRunner) runOne(ctx context.Context, idx int) (err error) {
+	if isRecovering() {
+		defer catchPanic(&err)
+	}
+	return runCancelable("Hedge", ctx, r.rs[idx])
+}
+
+func (r *hedgeRunner) Run() (err error) {
+	if err = r.ctx.Err(); err != nil {
+		return
+	}
+	atomic.AddUint64(&r.runs, 1)
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	ch := make(chan hedgeResult, len(r.rs))
+	started := 0
+	start := func() {
+		idx := started
+		started++
+		if idx > 0 {
+			atomic.AddUint64(&r.fired, 1)
+		}
+		spawn("Hedge", func() { ch <- hedgeResult{idx: idx, err: r.runOne(ctx, idx)} })
+	}
+
+	clk := clockOf(r.rs[0])
+	begin := clk.Now()
+	start()
+	timer := clk.NewTimer(r.delay())
+	defer timer.Stop()
+
+	errs := make([]error, len(r.rs))
+	for finished := 0; finished < started; {
+		select {
+		case <-timer.C():
+			if started < len(r.rs) && r.ctx.Err() == nil {
+				start()
+				timer.Reset(r.delay())
+			}
+		case res := <-ch:
+			finished++
+			if res.err != nil {
+				errs[res.idx] = res.err
+				// no need to wait if it failed
+				if started < len(r.rs) && r.ctx.Err() == nil {
+					start()
+					resetTimer(timer, r.delay())
+				}
+				continue
+			}
+
+			r.done(clk.Now().Sub(begin))
+			if res.idx > 0 {
+				atomic.AddUint64(&r.won, 1)
+			}
+			cancel()
+			for ; finished < started; finished++ {
+				<-ch
+			}
+			return nil
+		}
+	}
+
+	if err = r.ctx.Err(); err != nil {
+		return
+	}
+	return newMultiError(r.rs, errs)
+}
+
+func newHedgeRunner(delay func() time.Duration, done func(time.Duration), rs []Runner) *hedgeRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := CancelAll(rs...)
+	return &hedgeRunner{
+		rs:    rs,
+		delay: delay,
+		done:  done,
+		funcRunner: funcRunner{
+			ctx:    ctx,
+			cancel: func() { cancel(); c() },
+		},
+	}
+}
+
+// Hedge creates a Runner that runs rs[0], and runs next Runner of rs as a
+// backup if no one succeeds within delay
+//
+// It returns nil once any of them succeeds, and stops others (see
+// ContextRunner). A backup is also started right away if a running one fails.
+// If every Runner of rs fails, it returns a MultiError.
+//
+// It panics if rs is empty.
+//
+// You have to call Cancel() to release resources.
+func Hedge(delay time.Duration, rs ...Runner) HedgeRunner {
+	if len(rs) == 0 {
+		panic("no Runner for Hedge")
+	}
+	return newHedgeRunner(
+		func() time.Duration { return delay },
+		func(time.Duration) {},
+		rs,
+	)
+}
+
+// AdaptiveHedge is like Hedge, but the delay is p-th percentile (0 < p < 1) of
+// latest successful runs
+//
+// It waits initial before enough runs are recorded. 0.95 is a common choice of
+// p, so backups are started for slowest 5% runs.
+func AdaptiveHedge(p float64, initial time.Duration, rs ...Runner) HedgeRunner {
+	if len(rs) == 0 {
+		panic("no Runner for AdaptiveHedge")
+	}
+	l := &latencies{}
+	return newHedgeRunner(
+		func() time.Duration {
+			if d, ok := l.percentile(p); ok {
+				return d
+			}
+			return initial
+		},
+		l.add,
+		rs,
+	)
+}
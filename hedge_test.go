@@ -0,0 +1,193 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgeFast(t *testing.T) {
+	var backup int64
+	r := Hedge(time.Second,
+		CTXRunner(func(ctx context.Context) error { return nil }),
+		CTXRunner(func(ctx context.Context) error {
+			atomic.AddInt64(&backup, 1)
+			return nil
+		}),
+	)
+	defer r.Cancel()
+
+	if err := r.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if backup != 0 {
+		t.Fatal("backup should not run")
+	}
+	if s := r.Stats(); s != (HedgeStats{Runs: 1}) {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestHedgeSlow(t *testing.T) {
+	var stopped int64
+	r := Hedge(10*time.Millisecond,
+		CTXRunner(func(ctx context.Context) error {
+			<-ctx.Done()
+			atomic.AddInt64(&stopped, 1)
+			return ctx.Err()
+		}),
+		CTXRunner(func(ctx context.Context) error { return nil }),
+	)
+	defer r.Cancel()
+
+	begin := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := r.Run(); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	if dur := time.Since(begin); dur < 20*time.Millisecond {
+		t.Fatal("backup started too early:", dur)
+	}
+	if stopped != 2 {
+		t.Fatal("expected primary to be stopped, got", stopped)
+	}
+	if s := r.Stats(); s != (HedgeStats{Runs: 2, Fired: 2, Won: 2}) {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestHedgeFailure(t *testing.T) {
+	e1, e2 := errors.New("1"), errors.New("2")
+	r := Hedge(time.Second,
+		CTXRunner(func(ctx context.Context) error { return e1 }),
+		CTXRunner(func(ctx context.Context) error { return e2 }),
+	)
+	defer r.Cancel()
+
+	begin := time.Now()
+	err := r.Run()
+	if dur := time.Since(begin); dur > 500*time.Millisecond {
+		t.Fatal("expected backup to start right after failure, took", dur)
+	}
+	var m MultiError
+	if !errors.As(err, &m) || len(m) != 2 || m[0].Err != e1 || m[1].Err != e2 {
+		t.Fatal("unexpected error:", err)
+	}
+	if s := r.Stats(); s != (HedgeStats{Runs: 1, Fired: 1}) {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestHedgeCancel(t *testing.T) {
+	start := make(chan struct{})
+	r := Hedge(time.Second,
+		CTXRunner(func(ctx context.Context) error {
+			close(start)
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		CTXRunner(func(ctx context.Context) error { return nil }),
+	)
+
+	go func() {
+		<-start
+		r.Cancel()
+	}()
+	if err := r.Run(); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+	if s := r.Stats(); s.Fired != 0 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestLatencies(t *testing.T) {
+	l := &latencies{}
+	for i := 1; i < hedgeMinSamples; i++ {
+		l.add(time.Duration(i))
+	}
+	if _, ok := l.percentile(0.5); ok {
+		t.Fatal("expected not enough samples")
+	}
+
+	// older samples are overwritten
+	for i := hedgeWindow - 1; i >= 0; i-- {
+		l.add(time.Duration(i))
+	}
+	if d, _ := l.percentile(0.5); d != hedgeWindow/2 {
+		t.Fatal("unexpected 50th percentile:", d)
+	}
+	if d, _ := l.percentile(1); d != hedgeWindow-1 {
+		t.Fatal("unexpected 100th percentile:", d)
+	}
+}
+
+func TestAdaptiveHedge(t *testing.T) {
+	var slow int64
+	r := AdaptiveHedge(0.5, time.Second,
+		CTXRunner(func(ctx context.Context) error {
+			if atomic.LoadInt64(&slow) == 0 {
+				return nil
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		CTXRunner(func(ctx context.Context) error { return nil }),
+	)
+	defer r.Cancel()
+
+	for i := 0; i < hedgeMinSamples; i++ {
+		if err := r.Run(); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	// learned delay is far less than initial delay
+	atomic.StoreInt64(&slow, 1)
+	begin := time.Now()
+	if err := r.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if dur := time.Since(begin); dur > 500*time.Millisecond {
+		t.Fatal("expected learned delay, took", dur)
+	}
+	if s := r.Stats(); s.Won != 1 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestHedgeFailureResetsDelay(t *testing.T) {
+	e := errors.New("")
+	started := make(chan time.Time, 2)
+	block := CTXRunner(func(ctx context.Context) error {
+		started <- time.Now()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	r := Hedge(50*time.Millisecond,
+		CTXRunner(func(ctx context.Context) error {
+			time.Sleep(40 * time.Millisecond)
+			return e
+		}),
+		block,
+		block,
+	)
+
+	done := make(chan error, 1)
+	go func() { done <- r.Run() }()
+	second, third := <-started, <-started
+	r.Cancel()
+	<-done
+
+	// 2nd Runner starts right after 1st fails, 3rd starts delay after that
+	if gap := third.Sub(second); gap < 30*time.Millisecond {
+		t.Fatal("3rd Runner started too early:", gap)
+	}
+}
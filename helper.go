@@ -22,7 +22,7 @@ func (s ErrSignalReceived) Error() string {
 
 // SignalRunner creates a Runner that waits first signal in sig and returns it as error
 func SignalRunner(sig ...os.Signal) Runner {
-	ch := make(chan os.Signal)
+	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, sig...)
 	once := sync.Once{}
 	f := func() {
@@ -47,6 +47,9 @@ func SignalRunner(sig ...os.Signal) Runner {
 }
 
 // CancelOnSignal runs r and calls r.Cancel when receiving first signal in sig
+//
+// It waits r to return after canceled, see ShutdownController if you need a way
+// to force it.
 func CancelOnSignal(r Runner, sig ...os.Signal) (err error) {
 	x := Skip(r, SignalRunner(sig...))
 	defer x.Cancel()
@@ -0,0 +1,17 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package hook exposes internals of ctxroutines to its subpackages
+//
+// Functions are set when ctxroutines is initialized, so packages using them
+// must import ctxroutines too.
+package hook
+
+var (
+	// Spawn runs f in a new goroutine, and tracks it as owner if
+	// ctxroutines.TrackGoroutines is enabled
+	Spawn func(owner string, f func())
+	// Recovering reports if ctxroutines.RecoverPanics is enabled
+	Recovering func() bool
+)
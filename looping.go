@@ -13,7 +13,8 @@ type loopRunner struct {
 }
 
 func (r *loopRunner) Run() (err error) {
-	for {
+	o := observerOf(r)
+	for n := uint64(1); ; n++ {
 		var term bool
 		select {
 		case <-r.Context().Done():
@@ -21,7 +22,8 @@ func (r *loopRunner) Run() (err error) {
 		default:
 		}
 
-		err = r.Runner.Run()
+		o.Attempt(n)
+		err = runChild(r.Runner)
 
 		err, term = r.term(err)
 
@@ -48,11 +50,48 @@ func RetryWithCB(r Runner, cb func(error)) (ret Runner) {
 	return &loopRunner{
 		Runner: r,
 		cb:     cb,
+		term:   untilSuccess,
+	}
+}
+
+func untilSuccess(e error) (err error, term bool) {
+	if e == nil || e == context.Canceled {
+		return e, true
+	}
+	return e, false
+}
+
+// RetryWithBackoff creates a Runner runs r until it returns nil, and waits
+// between each try according to b
+//
+// Waiting is interrupted once r is canceled. b is reset after r succeeded, so
+// it can be used with Loop:
+//
+//     Loop(RetryWithBackoff(r, CappedBackoff(
+//         time.Minute,
+//         ExponentialBackoff(time.Second, 2),
+//     )))
+//
+// You have to call Cancel() to release resources.
+func RetryWithBackoff(r Runner, b Backoff) (ret Runner) {
+	return RetryWithBackoffCB(r, b, func(error) {})
+}
+
+// RetryWithBackoffCB is like RetryWithBackoff, but calls cb if r returns error.
+//
+// You have to call Cancel() to release resources.
+func RetryWithBackoffCB(r Runner, b Backoff, cb func(error)) (ret Runner) {
+	return &loopRunner{
+		Runner: r,
+		cb: func(e error) {
+			cb(e)
+			sleep(r.Context(), b.Next())
+		},
 		term: func(e error) (err error, term bool) {
-			if e == nil || e == context.Canceled {
-				return e, true
+			if e == nil {
+				b.Reset()
 			}
-			return e, false
+			return untilSuccess(e)
 		},
 	}
 }
@@ -0,0 +1,235 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package metrics collects metrics of Runners and exports them in Prometheus
+// text exposition format
+//
+//     reg := metrics.NewRegistry()
+//     r := Loop(RatelimitRunner(l, reg.Wrap("crawler", CTXRunner(myCrawler))))
+//     http.Handle("/metrics", reg)
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/raohwork/ctxroutines"
+)
+
+// DefBuckets is default buckets of duration histogram, in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type runnerMetrics struct {
+	lock      sync.Mutex
+	runs      uint64
+	errors    uint64
+	canceled  uint64
+	inFlight  int64
+	buckets   []float64
+	counts    []uint64
+	sum       float64
+	throttled float64
+	ctxroutines.NopObserver
+}
+
+func (m *runnerMetrics) RunStart() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.inFlight++
+}
+
+func (m *runnerMetrics) RunEnd(err error, dur time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.inFlight--
+	m.runs++
+	switch {
+	case errors.Is(err, context.Canceled):
+		m.canceled++
+	case err != nil:
+		m.errors++
+	}
+
+	sec := dur.Seconds()
+	m.sum += sec
+	m.counts[sort.SearchFloat64s(m.buckets, sec)]++
+}
+
+func (m *runnerMetrics) Throttled(wait time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.throttled += wait.Seconds()
+}
+
+// Registry collects metrics of Runners by name
+//
+// It is an http.Handler serving collected metrics in Prometheus text
+// exposition format.
+type Registry struct {
+	lock    sync.Mutex
+	buckets []float64
+	runners map[string]*runnerMetrics
+}
+
+// NewRegistry creates a Registry, buckets (in seconds) defaults to DefBuckets
+func NewRegistry(buckets ...float64) (ret *Registry) {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	b := make([]float64, len(buckets))
+	copy(b, buckets)
+	sort.Float64s(b)
+
+	return &Registry{
+		buckets: b,
+		runners: map[string]*runnerMetrics{},
+	}
+}
+
+func (reg *Registry) get(name string) (ret *runnerMetrics) {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+
+	ret, ok := reg.runners[name]
+	if !ok {
+		ret = &runnerMetrics{
+			buckets: reg.buckets,
+			// last one is +Inf
+			counts: make([]uint64, len(reg.buckets)+1),
+		}
+		reg.runners[name] = ret
+	}
+	return
+}
+
+// Observer returns the Observer collecting metrics of name
+//
+// Wrap attaches it to the Runner, so you need it only if you want to collect
+// metrics from inner Runner, see ctxroutines.Observer for detail.
+func (reg *Registry) Observer(name string) ctxroutines.Observer {
+	return reg.get(name)
+}
+
+// Wrap creates a Runner that collects metrics of r as name
+//
+// Metrics of Runners with same name are summed up. Rate limit wait time is
+// collected if the returned Runner is wrapped by RatelimitRunner. It's also a
+// ctxroutines.ContextRunner if r is.
+func (reg *Registry) Wrap(name string, r ctxroutines.Runner) ctxroutines.Runner {
+	return ctxroutines.Observe(r, reg.get(name))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type metricWriter struct {
+	*bufio.Writer
+}
+
+func (w metricWriter) header(name, typ, help string) {
+	w.WriteString("# HELP " + name + " " + help + "\n")
+	w.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+func (w metricWriter) sample(name, labels string, v float64) {
+	w.WriteString(name + "{" + labels + "} " + formatFloat(v) + "\n")
+}
+
+// WriteTo writes all metrics to w in Prometheus text exposition format
+func (reg *Registry) WriteTo(w io.Writer) (n int64, err error) {
+	reg.lock.Lock()
+	names := make([]string, 0, len(reg.runners))
+	for name := range reg.runners {
+		names = append(names, name)
+	}
+	runners := make([]*runnerMetrics, len(names))
+	sort.Strings(names)
+	for idx, name := range names {
+		runners[idx] = reg.runners[name]
+	}
+	reg.lock.Unlock()
+
+	cw := &countWriter{w: w}
+	mw := metricWriter{bufio.NewWriter(cw)}
+	labels := make([]string, len(names))
+	for idx, name := range names {
+		labels[idx] = `runner="` + labelEscaper.Replace(name) + `"`
+	}
+
+	each := func(name, typ, help string, f func(m *runnerMetrics) float64) {
+		mw.header(name, typ, help)
+		for idx, m := range runners {
+			m.lock.Lock()
+			v := f(m)
+			m.lock.Unlock()
+			mw.sample(name, labels[idx], v)
+		}
+	}
+
+	each("ctxroutines_runs_total", "counter", "Number of finished runs.", func(m *runnerMetrics) float64 {
+		return float64(m.runs)
+	})
+	each("ctxroutines_errors_total", "counter", "Number of runs returned error other than context.Canceled.", func(m *runnerMetrics) float64 {
+		return float64(m.errors)
+	})
+	each("ctxroutines_canceled_total", "counter", "Number of runs returned context.Canceled.", func(m *runnerMetrics) float64 {
+		return float64(m.canceled)
+	})
+	each("ctxroutines_in_flight", "gauge", "Number of running runs.", func(m *runnerMetrics) float64 {
+		return float64(m.inFlight)
+	})
+	each("ctxroutines_ratelimit_wait_seconds_total", "counter", "Time spent waiting for rate limit.", func(m *runnerMetrics) float64 {
+		return m.throttled
+	})
+
+	const hist = "ctxroutines_run_duration_seconds"
+	mw.header(hist, "histogram", "Duration of runs.")
+	for idx, m := range runners {
+		m.lock.Lock()
+		cnt := uint64(0)
+		for x, le := range append(m.buckets, math.Inf(1)) {
+			cnt += m.counts[x]
+			mw.sample(hist+"_bucket", labels[idx]+`,le="`+formatFloat(le)+`"`, float64(cnt))
+		}
+		mw.sample(hist+"_sum", labels[idx], m.sum)
+		mw.sample(hist+"_count", labels[idx], float64(cnt))
+		m.lock.Unlock()
+	}
+
+	err = mw.Flush()
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(buf []byte) (n int, err error) {
+	n, err = w.w.Write(buf)
+	w.n += int64(n)
+	return
+}
+
+// ServeHTTP implements http.Handler
+func (reg *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	reg.WriteTo(w)
+}
@@ -0,0 +1,116 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raohwork/ctxroutines"
+	"golang.org/x/time/rate"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry(0.01, 1)
+	e := errors.New("")
+	f := ctxroutines.Counter(func(n uint64) error {
+		switch n {
+		case 0:
+			return e
+		case 1:
+			return context.Canceled
+		}
+		return nil
+	})
+	r := ctxroutines.RatelimitRunner(
+		rate.NewLimiter(rate.Every(10*time.Millisecond), 1),
+		reg.Wrap(`a"b`, f),
+	)
+	for i := 0; i < 3; i++ {
+		r.Run()
+	}
+
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatal("unexpected content type:", ct)
+	}
+	buf, _ := io.ReadAll(resp.Body)
+	body := string(buf)
+
+	expect := []string{
+		"# TYPE ctxroutines_runs_total counter",
+		`ctxroutines_runs_total{runner="a\"b"} 3`,
+		`ctxroutines_errors_total{runner="a\"b"} 1`,
+		`ctxroutines_canceled_total{runner="a\"b"} 1`,
+		`ctxroutines_in_flight{runner="a\"b"} 0`,
+		"# TYPE ctxroutines_run_duration_seconds histogram",
+		`ctxroutines_run_duration_seconds_bucket{runner="a\"b",le="0.01"} 3`,
+		`ctxroutines_run_duration_seconds_bucket{runner="a\"b",le="+Inf"} 3`,
+		`ctxroutines_run_duration_seconds_count{runner="a\"b"} 3`,
+	}
+	for _, l := range expect {
+		if !strings.Contains(body, l+"\n") {
+			t.Errorf("expected line %s, got\n%s", l, body)
+		}
+	}
+	if strings.Contains(body, `ctxroutines_ratelimit_wait_seconds_total{runner="a\"b"} 0`+"\n") {
+		t.Error("expected rate limit wait time to be recorded")
+	}
+}
+
+func TestRegistryObserver(t *testing.T) {
+	reg := NewRegistry()
+	e := errors.New("")
+	r := ctxroutines.Observe(ctxroutines.NoCancelRunner(func() error { return e }), reg.Observer("x"))
+	for i := 0; i < 3; i++ {
+		r.Run()
+	}
+
+	buf := &strings.Builder{}
+	reg.WriteTo(buf)
+	for _, l := range []string{
+		`ctxroutines_runs_total{runner="x"} 3`,
+		`ctxroutines_errors_total{runner="x"} 3`,
+		`ctxroutines_run_duration_seconds_count{runner="x"} 3`,
+	} {
+		if !strings.Contains(buf.String(), l+"\n") {
+			t.Errorf("expected line %s, got\n%s", l, buf.String())
+		}
+	}
+}
+
+func TestWrapWithTimeout(t *testing.T) {
+	ctxroutines.TrackGoroutines(true)
+	defer ctxroutines.TrackGoroutines(false)
+
+	reg := NewRegistry()
+	f := ctxroutines.CTXRunner(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	defer f.Cancel()
+	r := ctxroutines.WithTimeout(10*time.Millisecond, reg.Wrap("a", f))
+	if err := r.Run(); err != ctxroutines.ErrRunTimeout {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for i := 0; len(ctxroutines.LiveGoroutines()) != 0; i++ {
+		if i == 100 {
+			t.Fatal("expected run to be stopped, got", ctxroutines.LiveGoroutines())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
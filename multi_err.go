@@ -0,0 +1,137 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// NamedRunner is a Runner with a name, which is used in ChildError
+type NamedRunner interface {
+	Runner
+	Name() string
+}
+
+type namedRunner struct {
+	name string
+	Runner
+}
+
+func (r *namedRunner) Name() string { return r.name }
+
+type namedCtxRunner struct {
+	namedRunner
+}
+
+func (r *namedCtxRunner) RunContext(ctx context.Context) error {
+	return r.Runner.(ContextRunner).RunContext(ctx)
+}
+
+// Named gives r a name
+//
+// The returned Runner is also a ContextRunner if r is.
+func Named(name string, r Runner) NamedRunner {
+	if _, ok := r.(ContextRunner); ok {
+		return &namedCtxRunner{namedRunner{name: name, Runner: r}}
+	}
+	return &namedRunner{name: name, Runner: r}
+}
+
+func nameOf(r Runner) string {
+	if x, ok := r.(NamedRunner); ok {
+		return x.Name()
+	}
+	return ""
+}
+
+// ChildError records which child Runner returns the error
+type ChildError struct {
+	// index in the list of Runners passed to the combinator
+	Index int
+	// name of the Runner if it's a NamedRunner
+	Name string
+	Err  error
+}
+
+func (e ChildError) Error() string {
+	id := "#" + strconv.Itoa(e.Index)
+	if e.Name != "" {
+		id += " (" + e.Name + ")"
+	}
+	return id + ": " + e.Err.Error()
+}
+
+func (e ChildError) Unwrap() error { return e.Err }
+
+// MultiError collects errors from several child Runners
+//
+// errors.Is and errors.As check every error in it.
+type MultiError []ChildError
+
+func (m MultiError) Error() string {
+	if len(m) == 1 {
+		return m[0].Error()
+	}
+
+	msgs := make([]string, len(m))
+	for idx, e := range m {
+		msgs[idx] = e.Error()
+	}
+	return strconv.Itoa(len(m)) + " errors occurred: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns every child error
+func (m MultiError) Unwrap() []error {
+	ret := make([]error, len(m))
+	for idx, e := range m {
+		ret[idx] = e
+	}
+	return ret
+}
+
+// Is reports if any child error matches target
+func (m MultiError) Is(target error) bool {
+	for _, e := range m {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds first child error that matches target
+func (m MultiError) As(target interface{}) bool {
+	for _, e := range m {
+		if errors.As(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// newMultiError collects non-nil errors in errs, which is returned by rs
+//
+// It returns nil if there's no error.
+func newMultiError(rs []Runner, errs []error) error {
+	var ret MultiError
+	for idx, err := range errs {
+		if err == nil {
+			continue
+		}
+		ret = append(ret, ChildError{
+			Index: idx,
+			Name:  nameOf(rs[idx]),
+			Err:   err,
+		})
+	}
+
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret
+}
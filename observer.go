@@ -0,0 +1,121 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"time"
+)
+
+// Observer receives events from Runners
+//
+// Observer is passed through context. Combinators look for it in the context
+// of the Runner they wrap, so it must be attached to the inner Runner:
+//
+//     r := Loop(RatelimitRunner(l, Observe(f, o))) // o receives all events
+//     r := Observe(Loop(RatelimitRunner(l, f)), o) // o receives only RunStart, RunEnd and Canceled
+//
+// Methods might be called concurrently, and should return as soon as possible.
+type Observer interface {
+	// Run() is called
+	RunStart()
+	// Run() returns
+	RunEnd(err error, dur time.Duration)
+	// n-th iteration of Loop, or n-th try of Retry and TryAtMost, starting from 1
+	Attempt(n uint64)
+	// RatelimitRunner has to wait before running
+	Throttled(wait time.Duration)
+	// Cancel() is called
+	Canceled()
+}
+
+// NopObserver is an Observer that ignores every event
+//
+// It's useful to be embedded in your Observer if you need only few events.
+type NopObserver struct{}
+
+func (NopObserver) RunStart()                   {}
+func (NopObserver) RunEnd(error, time.Duration) {}
+func (NopObserver) Attempt(uint64)              {}
+func (NopObserver) Throttled(time.Duration)     {}
+func (NopObserver) Canceled()                   {}
+
+type multiObserver []Observer
+
+func (m multiObserver) RunStart() {
+	for _, o := range m {
+		o.RunStart()
+	}
+}
+func (m multiObserver) RunEnd(err error, dur time.Duration) {
+	for _, o := range m {
+		o.RunEnd(err, dur)
+	}
+}
+func (m multiObserver) Attempt(n uint64) {
+	for _, o := range m {
+		o.Attempt(n)
+	}
+}
+func (m multiObserver) Throttled(wait time.Duration) {
+	for _, o := range m {
+		o.Throttled(wait)
+	}
+}
+func (m multiObserver) Canceled() {
+	for _, o := range m {
+		o.Canceled()
+	}
+}
+
+// MultiObserver creates an Observer that dispatches events to every Observer
+// of os in order
+func MultiObserver(os ...Observer) Observer {
+	return multiObserver(os)
+}
+
+type observerKey struct{}
+
+// WithObserver creates a context carrying o
+//
+// It replaces the Observer in ctx, use MultiObserver if you need both.
+func WithObserver(ctx context.Context, o Observer) context.Context {
+	return context.WithValue(ctx, observerKey{}, o)
+}
+
+// ObserverFrom returns the Observer in ctx, or NopObserver if there's none
+func ObserverFrom(ctx context.Context) Observer {
+	if o, ok := ctx.Value(observerKey{}).(Observer); ok {
+		return o
+	}
+	return NopObserver{}
+}
+
+func observerOf(r Runner) Observer {
+	return ObserverFrom(r.Context())
+}
+
+// Observe creates a Runner that reports events of r to o
+//
+// o is also attached to the context of returned Runner, so combinators
+// wrapping it reports events to o. It's also a ContextRunner if r is.
+func Observe(r Runner, o Observer) Runner {
+	return wrapRunner(
+		r,
+		WithObserver(r.Context(), o),
+		func() {
+			o.Canceled()
+			r.Cancel()
+		},
+		func(run func() error) error {
+			o.RunStart()
+			clk := clockOf(r)
+			begin := clk.Now()
+			err := run()
+			o.RunEnd(err, clk.Now().Sub(begin))
+			return err
+		},
+	)
+}
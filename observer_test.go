@@ -0,0 +1,109 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type testObserver struct {
+	lock      sync.Mutex
+	starts    int
+	ends      []error
+	attempts  []uint64
+	throttled int
+	canceled  int
+}
+
+func (o *testObserver) RunStart() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.starts++
+}
+func (o *testObserver) RunEnd(err error, dur time.Duration) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.ends = append(o.ends, err)
+}
+func (o *testObserver) Attempt(n uint64) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.attempts = append(o.attempts, n)
+}
+func (o *testObserver) Throttled(time.Duration) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.throttled++
+}
+func (o *testObserver) Canceled() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.canceled++
+}
+
+func TestObserveRetry(t *testing.T) {
+	o := &testObserver{}
+	e := errors.New("")
+	f := Counter(func(n uint64) error {
+		if n < 2 {
+			return e
+		}
+		return nil
+	})
+
+	if err := Retry(Observe(f, o)).Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if o.starts != 3 || len(o.ends) != 3 {
+		t.Fatalf("expected 3 runs, got %d starts and %d ends", o.starts, len(o.ends))
+	}
+	if o.ends[0] != e || o.ends[2] != nil {
+		t.Fatal("unexpected errors:", o.ends)
+	}
+	if len(o.attempts) != 3 || o.attempts[2] != 3 {
+		t.Fatal("unexpected attempts:", o.attempts)
+	}
+}
+
+func TestObserveTryAtMost(t *testing.T) {
+	o := &testObserver{}
+	f := CTXRunner(func(context.Context) error { return errors.New("") })
+	TryAtMost(2, Observe(f, o)).Run()
+	if len(o.attempts) != 2 || o.attempts[0] != 1 || o.attempts[1] != 2 {
+		t.Fatal("unexpected attempts:", o.attempts)
+	}
+}
+
+func TestObserveThrottled(t *testing.T) {
+	o := &testObserver{}
+	f := Observe(NoCancelRunner(func() error { return nil }), o)
+	r := RatelimitRunner(rate.NewLimiter(rate.Every(time.Millisecond), 1), f)
+	r.Run()
+	r.Run()
+	if o.throttled != 1 {
+		t.Fatalf("expected throttled once, got %d", o.throttled)
+	}
+}
+
+func TestObserveCanceled(t *testing.T) {
+	o := &testObserver{}
+	r := Loop(Observe(CTXRunner(func(c context.Context) error {
+		<-c.Done()
+		return c.Err()
+	}), o))
+	go func() { time.Sleep(time.Millisecond); r.Cancel() }()
+	if err := r.Run(); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+	if o.canceled != 1 {
+		t.Fatalf("expected canceled once, got %d", o.canceled)
+	}
+}
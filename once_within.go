@@ -25,6 +25,8 @@ func newOnceWithin(dur time.Duration, r StatefulRunner) (ret Runner) {
 //     r.Run() // skipped
 //     time.Sleep(time.Second)
 //     r.Run() // runs f
+//
+// See Debounce and Throttle if the last call must not be dropped.
 func OnceWithin(dur time.Duration, f Runner) (ret Runner) {
 	return newOnceWithin(
 		dur,
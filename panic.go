@@ -0,0 +1,85 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// ErrPanic indicates the Runner panics
+type ErrPanic struct {
+	// the value passed to panic()
+	Value interface{}
+	// stack trace of the panicking goroutine
+	Stack []byte
+}
+
+func (e ErrPanic) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the value passed to panic() if it is an error
+func (e ErrPanic) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// catchPanic MUST be called with defer
+func catchPanic(err *error) {
+	if v := recover(); v != nil {
+		*err = ErrPanic{
+			Value: v,
+			Stack: debug.Stack(),
+		}
+	}
+}
+
+// Recover creates a Runner that converts panics in r into ErrPanic
+//
+// It's also a ContextRunner if r is.
+func Recover(r Runner) Runner {
+	return wrapRunner(r, r.Context(), r.Cancel, func(run func() error) (err error) {
+		defer catchPanic(&err)
+		return run()
+	})
+}
+
+var recoverPanics int32
+
+// RecoverPanics controls whether Runners are protected by Recover when they are
+// run by combinators in this package
+//
+// It affects every goroutine spawned by this package (like Run, Skip and
+// AnyErr), and also Loop, Retry and TryAtMost, so a panic is treated like any
+// other error. It is disabled by default.
+func RecoverPanics(enable bool) {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&recoverPanics, v)
+}
+
+func isRecovering() bool {
+	return atomic.LoadInt32(&recoverPanics) == 1
+}
+
+// runChild runs r, recovers from panic if RecoverPanics is enabled
+func runChild(r Runner) (err error) {
+	if isRecovering() {
+		defer catchPanic(&err)
+	}
+	return r.Run()
+}
+
+// runResultChild is generic version of runChild
+func runResultChild[T any](r ResultRunner[T]) (v T, err error) {
+	if isRecovering() {
+		defer catchPanic(&err)
+	}
+	return r.Run()
+}
@@ -0,0 +1,57 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	e := errors.New("")
+	err := Recover(NoCancelRunner(func() error { panic(e) })).Run()
+
+	var x ErrPanic
+	if !errors.As(err, &x) {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(x.Stack) == 0 {
+		t.Error("expected stack trace")
+	}
+	if !errors.Is(err, e) {
+		t.Error("expected to unwrap panic value")
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	RecoverPanics(true)
+	defer RecoverPanics(false)
+
+	p := NoCancelRunner(func() error { panic("boom") })
+	var x ErrPanic
+	if err := Skip(p).Run(); !errors.As(err, &x) {
+		t.Fatal("unexpected error from Skip:", err)
+	}
+	if err := AnyErr(p, NoCancelRunner(func() error { return nil })).Run(); !errors.As(err, &x) {
+		t.Fatal("unexpected error from AnyErr:", err)
+	}
+	if err := TryAtMost(3, p).Run(); !errors.As(err, &x) {
+		t.Fatal("unexpected error from TryAtMost:", err)
+	}
+
+	cnt := 0
+	f := NoCancelRunner(func() error {
+		if cnt++; cnt < 3 {
+			panic("boom")
+		}
+		return nil
+	})
+	if err := Retry(f).Run(); err != nil {
+		t.Fatal("unexpected error from Retry:", err)
+	}
+	if cnt != 3 {
+		t.Fatalf("expected retried 3 times, got %d", cnt)
+	}
+}
@@ -0,0 +1,316 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package pipeline builds multi-stage channel pipelines as a single Runner
+//
+//     p := pipeline.New()
+//     urls := pipeline.Source(p, func(ctx context.Context, out chan<- string) error {
+//         for _, u := range list {
+//             if err := pipeline.Send(ctx, out, u); err != nil {
+//                 return err
+//             }
+//         }
+//         return nil
+//     })
+//     pages := pipeline.Map(p, urls, 8, pipeline.Ordered, fetch)
+//     pipeline.Sink(p, pages, save)
+//
+//     err := p.Run() // or pass p to any function accepting ctxroutines.Runner
+//
+// Every stage runs in its own goroutines, and the first error returned by any
+// stage cancels whole pipeline. Output channel of a stage is closed after the
+// stage returns, so a stage can simply range over its input.
+//
+// Like other combinators, the goroutines respect ctxroutines.RecoverPanics and
+// ctxroutines.TrackGoroutines.
+package pipeline
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/raohwork/ctxroutines"
+	"github.com/raohwork/ctxroutines/internal/hook"
+)
+
+// group runs functions in separated goroutines, and cancels others once a
+// function returns error
+type group struct {
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+	cancel context.CancelFunc
+}
+
+func newGroup(ctx context.Context) (*group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &group{cancel: cancel}, ctx
+}
+
+func (g *group) Go(f func() error) {
+	g.wg.Add(1)
+	hook.Spawn("Pipeline", func() {
+		defer g.wg.Done()
+		if err := run(f); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	})
+}
+
+// run calls f, converts panics into ctxroutines.ErrPanic if
+// ctxroutines.RecoverPanics is enabled
+func run(f func() error) (err error) {
+	if hook.Recovering() {
+		defer func() {
+			if v := recover(); v != nil {
+				err = ctxroutines.ErrPanic{Value: v, Stack: debug.Stack()}
+			}
+		}()
+	}
+	return f()
+}
+
+// Wait waits all functions to return, and returns first error
+func (g *group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+// Pipeline is a ctxroutines.Runner that runs every stage added to it
+//
+// Stages must be added before calling Run(), and Run() can be called only
+// once.
+type Pipeline struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started int32
+	stages  []func(context.Context) error
+}
+
+// New creates an empty Pipeline
+func New() (ret *Pipeline) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pipeline{ctx: ctx, cancel: cancel}
+}
+
+func (p *Pipeline) Context() context.Context { return p.ctx }
+
+// Cancel stops every stage
+func (p *Pipeline) Cancel() { p.cancel() }
+
+func (p *Pipeline) add(f func(context.Context) error) {
+	p.stages = append(p.stages, f)
+}
+
+// Run runs every stage, blocks until all stages return
+//
+// It returns first error returned by any stage, or context.Canceled if
+// canceled. Goroutines of every stage are returned before Run() returns.
+func (p *Pipeline) Run() (err error) {
+	if !atomic.CompareAndSwapInt32(&p.started, 0, 1) {
+		return context.Canceled
+	}
+	if err = p.ctx.Err(); err != nil {
+		return
+	}
+
+	g, ctx := newGroup(p.ctx)
+	for _, s := range p.stages {
+		f := s
+		g.Go(func() error { return f(ctx) })
+	}
+	return g.Wait()
+}
+
+// Send sends v to out, returns ctx.Err() if ctx is done before that
+func Send[T any](ctx context.Context, out chan<- T, v T) error {
+	select {
+	case out <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Recv receives a value from in. ok is false if in is closed or ctx is done.
+func Recv[T any](ctx context.Context, in <-chan T) (v T, ok bool) {
+	select {
+	case v, ok = <-in:
+		return
+	case <-ctx.Done():
+		return
+	}
+}
+
+// Source adds a stage that produces values
+//
+// f should send values with Send, and returns when done. The returned channel
+// is closed after f returns.
+func Source[T any](p *Pipeline, f func(ctx context.Context, out chan<- T) error) <-chan T {
+	out := make(chan T)
+	p.add(func(ctx context.Context) error {
+		defer close(out)
+		return f(ctx, out)
+	})
+	return out
+}
+
+// Stage adds a stage that runs n copies of f concurrently
+//
+// f should read from in until it's closed, and send values with Send. The
+// returned channel is closed after every copy of f returns.
+//
+// It panics if n is not positive.
+func Stage[I, O any](p *Pipeline, in <-chan I, n int, f func(ctx context.Context, in <-chan I, out chan<- O) error) <-chan O {
+	if n <= 0 {
+		panic("non-positive number of workers for Stage")
+	}
+	out := make(chan O)
+	p.add(func(ctx context.Context) error {
+		defer close(out)
+		g, ctx := newGroup(ctx)
+		for i := 0; i < n; i++ {
+			g.Go(func() error { return f(ctx, in, out) })
+		}
+		return g.Wait()
+	})
+	return out
+}
+
+// Order decides whether Map keeps the order of values
+type Order int
+
+const (
+	// Unordered sends results as soon as they're ready
+	Unordered Order = iota
+	// Ordered sends results in the order of input
+	Ordered
+)
+
+// Map adds a stage that calls f for every value from in with n workers
+//
+// It panics if n is not positive.
+func Map[I, O any](p *Pipeline, in <-chan I, n int, order Order, f func(ctx context.Context, v I) (O, error)) <-chan O {
+	if n <= 0 {
+		panic("non-positive number of workers for Map")
+	}
+	if order == Unordered {
+		return Stage(p, in, n, func(ctx context.Context, in <-chan I, out chan<- O) error {
+			for {
+				v, ok := Recv(ctx, in)
+				if !ok {
+					return ctx.Err()
+				}
+				o, err := f(ctx, v)
+				if err != nil {
+					return err
+				}
+				if err = Send(ctx, out, o); err != nil {
+					return err
+				}
+			}
+		})
+	}
+
+	type result struct {
+		v   O
+		err error
+	}
+	out := make(chan O)
+	p.add(func(ctx context.Context) error {
+		defer close(out)
+		g, ctx := newGroup(ctx)
+		// results in input order
+		queue := make(chan chan result, n)
+		sem := make(chan struct{}, n)
+
+		g.Go(func() error {
+			defer close(queue)
+			for {
+				v, ok := Recv(ctx, in)
+				if !ok {
+					return ctx.Err()
+				}
+				if err := Send(ctx, sem, struct{}{}); err != nil {
+					return err
+				}
+				ch := make(chan result, 1)
+				if err := Send(ctx, queue, ch); err != nil {
+					return err
+				}
+				g.Go(func() error {
+					o, err := f(ctx, v)
+					ch <- result{v: o, err: err}
+					<-sem
+					return nil
+				})
+			}
+		})
+
+		g.Go(func() error {
+			for ch := range queue {
+				r, ok := Recv(ctx, ch)
+				if !ok {
+					return ctx.Err()
+				}
+				if r.err != nil {
+					return r.err
+				}
+				if err := Send(ctx, out, r.v); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		return g.Wait()
+	})
+	return out
+}
+
+// Merge adds a stage that sends every value from ins to returned channel
+func Merge[T any](p *Pipeline, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	p.add(func(ctx context.Context) error {
+		defer close(out)
+		g, ctx := newGroup(ctx)
+		for _, in := range ins {
+			c := in
+			g.Go(func() error {
+				for {
+					v, ok := Recv(ctx, c)
+					if !ok {
+						return ctx.Err()
+					}
+					if err := Send(ctx, out, v); err != nil {
+						return err
+					}
+				}
+			})
+		}
+		return g.Wait()
+	})
+	return out
+}
+
+// Sink adds a stage that calls f for every value from in
+func Sink[T any](p *Pipeline, in <-chan T, f func(ctx context.Context, v T) error) {
+	p.add(func(ctx context.Context) error {
+		for {
+			v, ok := Recv(ctx, in)
+			if !ok {
+				return ctx.Err()
+			}
+			if err := f(ctx, v); err != nil {
+				return err
+			}
+		}
+	})
+}
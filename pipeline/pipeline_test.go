@@ -0,0 +1,221 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raohwork/ctxroutines"
+)
+
+// make sure Pipeline is a Runner
+var _ ctxroutines.Runner = New()
+
+func numbers(p *Pipeline, n int) <-chan int {
+	return Source(p, func(ctx context.Context, out chan<- int) error {
+		for i := 0; i < n; i++ {
+			if err := Send(ctx, out, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func slowSquare(ctx context.Context, v int) (int, error) {
+	time.Sleep(time.Duration(10-v%10) * time.Millisecond)
+	return v * v, nil
+}
+
+func collect(p *Pipeline, in <-chan int) *[]int {
+	ret := &[]int{}
+	Sink(p, in, func(ctx context.Context, v int) error {
+		*ret = append(*ret, v)
+		return nil
+	})
+	return ret
+}
+
+func checkLeak(t *testing.T, before int) {
+	t.Helper()
+	after := 0
+	for i := 0; i < 100; i++ {
+		if after = runtime.NumGoroutine(); after <= before {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("goroutine leaked: %d before, %d after", before, after)
+}
+
+func TestMapOrdered(t *testing.T) {
+	p := New()
+	res := collect(p, Map(p, numbers(p, 20), 5, Ordered, slowSquare))
+	if err := p.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(*res) != 20 {
+		t.Fatalf("expected 20 results, got %d", len(*res))
+	}
+	for idx, v := range *res {
+		if v != idx*idx {
+			t.Fatalf("expected #%d to be %d, got %d", idx, idx*idx, v)
+		}
+	}
+}
+
+func TestMapUnordered(t *testing.T) {
+	p := New()
+	res := collect(p, Map(p, numbers(p, 20), 5, Unordered, slowSquare))
+	if err := p.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	sort.Ints(*res)
+	if len(*res) != 20 {
+		t.Fatalf("expected 20 results, got %d", len(*res))
+	}
+	for idx, v := range *res {
+		if v != idx*idx {
+			t.Fatalf("expected #%d to be %d, got %d", idx, idx*idx, v)
+		}
+	}
+}
+
+func TestStageMerge(t *testing.T) {
+	p := New()
+	double := func(ctx context.Context, in <-chan int, out chan<- int) error {
+		for v := range in {
+			if err := Send(ctx, out, v*2); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	a := Stage(p, numbers(p, 5), 2, double)
+	b := numbers(p, 5)
+	res := collect(p, Merge(p, a, b))
+	if err := p.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	sum := 0
+	for _, v := range *res {
+		sum += v
+	}
+	if len(*res) != 10 || sum != 30 {
+		t.Fatalf("unexpected result: %v", *res)
+	}
+}
+
+func TestError(t *testing.T) {
+	e := errors.New("")
+	before := runtime.NumGoroutine()
+
+	p := New()
+	m := Map(p, numbers(p, 1000), 4, Ordered, func(ctx context.Context, v int) (int, error) {
+		if v == 10 {
+			return 0, e
+		}
+		return v, nil
+	})
+	Sink(p, m, func(ctx context.Context, v int) error { return nil })
+	if err := p.Run(); err != e {
+		t.Fatal("unexpected error:", err)
+	}
+
+	checkLeak(t, before)
+}
+
+func TestCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	p := New()
+	infinite := Source(p, func(ctx context.Context, out chan<- int) error {
+		for {
+			if err := Send(ctx, out, 1); err != nil {
+				return err
+			}
+		}
+	})
+	once := sync.Once{}
+	started := make(chan struct{})
+	m := Map(p, infinite, 4, Unordered, func(ctx context.Context, v int) (int, error) {
+		once.Do(func() { close(started) })
+		return v, nil
+	})
+	Sink(p, m, func(ctx context.Context, v int) error { return nil })
+
+	go func() { <-started; p.Cancel() }()
+	if err := p.Run(); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+
+	checkLeak(t, before)
+}
+
+func TestPanic(t *testing.T) {
+	ctxroutines.RecoverPanics(true)
+	defer ctxroutines.RecoverPanics(false)
+	ctxroutines.TrackGoroutines(true)
+	defer ctxroutines.TrackGoroutines(false)
+
+	wait := make(chan struct{})
+	p := New()
+	Sink(p, numbers(p, 10), func(ctx context.Context, v int) error {
+		<-wait
+		panic("boom")
+	})
+	done := make(chan error, 1)
+	go func() { done <- p.Run() }()
+
+	for i := 0; len(ctxroutines.LiveGoroutines()) != 2; i++ {
+		if i == 100 {
+			t.Fatal("expected stages to be tracked, got", ctxroutines.LiveGoroutines())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, g := range ctxroutines.LiveGoroutines() {
+		if g.Owner != "Pipeline" {
+			t.Fatalf("unexpected goroutine info: %+v", g)
+		}
+	}
+	close(wait)
+
+	var x ctxroutines.ErrPanic
+	if err := <-done; !errors.As(err, &x) || x.Value != "boom" {
+		t.Fatal("expected ErrPanic, got", err)
+	}
+}
+
+func TestNoWorker(t *testing.T) {
+	cases := map[string]func(p *Pipeline, in <-chan int){
+		"Stage": func(p *Pipeline, in <-chan int) {
+			Stage(p, in, 0, func(ctx context.Context, in <-chan int, out chan<- int) error { return nil })
+		},
+		"Map": func(p *Pipeline, in <-chan int) {
+			Map(p, in, 0, Ordered, slowSquare)
+		},
+	}
+	for name, f := range cases {
+		f := f
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected to panic")
+				}
+			}()
+			p := New()
+			f(p, numbers(p, 1))
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrPoolClosed is returned when submitting a job to a canceled Pool
+	ErrPoolClosed = errors.New("pool is closed")
+	// ErrPoolFull is returned by TrySubmit when the queue is full
+	ErrPoolFull = errors.New("pool is full")
+)
+
+// Job is a function run by Pool
+type Job func(ctx context.Context) error
+
+// PoolMode decides what to do with queued jobs when Pool is canceled
+type PoolMode int
+
+const (
+	// PoolDrain finishes every queued job before Run() returns
+	PoolDrain PoolMode = iota
+	// PoolAbort cancels the context passed to running jobs, and drops queued
+	// jobs with context.Canceled
+	PoolAbort
+)
+
+// Pool is a Runner that runs submitted jobs with fixed number of workers
+//
+//     p := NewPool(4, 100, PoolDrain, func(err error) { log.Print(err) })
+//     go p.Run()
+//     p.Submit(ctx, job)
+//     p.Cancel() // stops accepting jobs, Run() returns after queued jobs are done
+type Pool struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	jobCtx    context.Context
+	jobCancel context.CancelFunc
+	workers   int
+	mode      PoolMode
+	onErr     func(error)
+
+	started int32
+	lock    sync.RWMutex
+	closed  bool
+	jobs    chan Job
+}
+
+// NewPool creates a Pool with n workers and a queue which holds at most queue
+// jobs
+//
+// Non-nil errors returned by jobs are passed to onErr, which might be called
+// concurrently. onErr can be nil.
+//
+// It panics if workers is not positive.
+func NewPool(workers, queue int, mode PoolMode, onErr func(error)) (ret *Pool) {
+	if workers <= 0 {
+		panic("non-positive number of workers for NewPool")
+	}
+	if onErr == nil {
+		onErr = func(error) {}
+	}
+	ret = &Pool{
+		workers: workers,
+		mode:    mode,
+		onErr:   onErr,
+		jobs:    make(chan Job, queue),
+	}
+	ret.ctx, ret.cancel = context.WithCancel(context.Background())
+	ret.jobCtx, ret.jobCancel = context.WithCancel(context.Background())
+	return
+}
+
+func (p *Pool) Context() context.Context { return p.ctx }
+func (p *Pool) Cancel()                  { p.cancel() }
+
+// Submit puts job into the queue, blocks until there's room in the queue
+//
+// It returns ctx.Err() if ctx is done before that, or ErrPoolClosed if the
+// Pool is canceled. Jobs can be submitted before calling Run().
+func (p *Pool) Submit(ctx context.Context, job Job) (err error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed || p.ctx.Err() != nil {
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.jobs <- job:
+		return
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.ctx.Done():
+		return ErrPoolClosed
+	}
+}
+
+// TrySubmit is like Submit, but returns ErrPoolFull instead of blocking
+func (p *Pool) TrySubmit(job Job) (err error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed || p.ctx.Err() != nil {
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.jobs <- job:
+		return
+	default:
+		return ErrPoolFull
+	}
+}
+
+// Queued returns number of jobs waiting in the queue
+func (p *Pool) Queued() int {
+	return len(p.jobs)
+}
+
+func (p *Pool) runJob(job Job) (err error) {
+	if isRecovering() {
+		defer catchPanic(&err)
+	}
+	if err = p.jobCtx.Err(); err != nil {
+		return
+	}
+	return job(p.jobCtx)
+}
+
+func (p *Pool) worker() {
+	for job := range p.jobs {
+		if err := p.runJob(job); err != nil {
+			p.onErr(err)
+		}
+	}
+}
+
+// Run starts workers and blocks until canceled
+//
+// It always returns context.Canceled. Run() can be called only once, further
+// calls return context.Canceled immediately.
+func (p *Pool) Run() (err error) {
+	if !atomic.CompareAndSwapInt32(&p.started, 0, 1) {
+		return context.Canceled
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(p.workers)
+	for i := 0; i < p.workers; i++ {
+		spawn("Pool", func() {
+			defer wg.Done()
+			p.worker()
+		})
+	}
+
+	<-p.ctx.Done()
+	// cancel before closing, so no queued job can run with live context
+	if p.mode == PoolAbort {
+		p.jobCancel()
+	}
+	p.lock.Lock()
+	p.closed = true
+	close(p.jobs)
+	p.lock.Unlock()
+
+	wg.Wait()
+	p.jobCancel()
+	return p.ctx.Err()
+}
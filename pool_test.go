@@ -0,0 +1,114 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolDrain(t *testing.T) {
+	e := errors.New("")
+	var errs, done int64
+	p := NewPool(2, 10, PoolDrain, func(err error) {
+		if err == e {
+			atomic.AddInt64(&errs, 1)
+		}
+	})
+
+	for i := 0; i < 10; i++ {
+		x := i
+		err := p.TrySubmit(func(c context.Context) error {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&done, 1)
+			if x%2 == 0 {
+				return e
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	if err := p.TrySubmit(func(context.Context) error { return nil }); err != ErrPoolFull {
+		t.Fatal("unexpected error:", err)
+	}
+
+	p.Cancel()
+	if err := p.Run(); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+	if done != 10 || errs != 5 {
+		t.Fatalf("expected 10 jobs done with 5 errors, got %d and %d", done, errs)
+	}
+	if err := p.Submit(context.Background(), nil); err != ErrPoolClosed {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestPoolAbort(t *testing.T) {
+	var canceled int64
+	p := NewPool(1, 10, PoolAbort, func(err error) {
+		if err == context.Canceled {
+			atomic.AddInt64(&canceled, 1)
+		}
+	})
+	started := make(chan struct{})
+	go p.Run()
+
+	p.Submit(context.Background(), func(c context.Context) error {
+		close(started)
+		<-c.Done()
+		return c.Err()
+	})
+	for i := 0; i < 3; i++ {
+		p.Submit(context.Background(), func(context.Context) error {
+			t.Error("expected queued job to be dropped")
+			return nil
+		})
+	}
+
+	<-started
+	p.Cancel()
+	if err := p.Run(); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+	waitFor(t, "jobs to be canceled", func() bool {
+		return atomic.LoadInt64(&canceled) == 4
+	})
+}
+
+func TestPoolSubmitBlocking(t *testing.T) {
+	wait := make(chan struct{})
+	p := NewPool(1, 0, PoolDrain, nil)
+	defer p.Cancel()
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() { defer wg.Done(); p.Run() }()
+
+	p.Submit(context.Background(), func(context.Context) error { <-wait; return nil })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Submit(ctx, func(context.Context) error { return nil }); err != context.DeadlineExceeded {
+		t.Fatal("unexpected error:", err)
+	}
+
+	close(wait)
+	p.Cancel()
+	wg.Wait()
+}
+
+func TestPoolNoWorker(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected to panic")
+		}
+	}()
+	NewPool(0, 1, PoolDrain, nil)
+}
@@ -34,23 +34,41 @@ type ratelimitRunner struct {
 	Runner
 }
 
-func (r *ratelimitRunner) sleep(timeout time.Duration) (canceled bool) {
+// sleep waits for timeout with the Clock in ctx, returns true if ctx is done
+// before that
+func sleep(ctx context.Context, timeout time.Duration) (canceled bool) {
+	if timeout <= 0 {
+		return ctx.Err() != nil
+	}
+
+	t := ClockFrom(ctx).NewTimer(timeout)
+	defer t.Stop()
 	select {
-	case <-r.Context().Done():
+	case <-ctx.Done():
 		return true
-	case <-time.After(timeout):
+	case <-t.C():
 		return false
 	}
 }
 
+func (r *ratelimitRunner) sleep(timeout time.Duration) (canceled bool) {
+	return sleep(r.Context(), timeout)
+}
+
 func (r *ratelimitRunner) Run() (err error) {
 	if IsCanceled(r) {
 		return context.Canceled
 	}
 
-	reserve := r.lim.Reserve()
-	if r.sleep(reserve.Delay()) {
-		reserve.Cancel()
+	clk := clockOf(r)
+	now := clk.Now()
+	reserve := r.lim.ReserveN(now, 1)
+	delay := reserve.DelayFrom(now)
+	if delay > 0 {
+		observerOf(r).Throttled(delay)
+	}
+	if r.sleep(delay) {
+		reserve.CancelAt(clk.Now())
 		return context.Canceled
 	}
 
@@ -0,0 +1,207 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"sync"
+)
+
+// ResultRunner is like Runner, but Run() returns a value
+type ResultRunner[T any] interface {
+	Context() context.Context
+	Cancel()
+	// Run SHOULD always return some error after canceled
+	Run() (T, error)
+}
+
+type funcResultRunner[T any] struct {
+	ctx    context.Context
+	cancel func()
+	f      func() (T, error)
+}
+
+func (r *funcResultRunner[T]) Context() context.Context { return r.ctx }
+func (r *funcResultRunner[T]) Cancel()                  { r.cancel() }
+func (r *funcResultRunner[T]) Run() (T, error)          { return r.f() }
+
+// NewResultRunner creates a basic ResultRunner
+func NewResultRunner[T any](ctx context.Context, cancel context.CancelFunc, f func() (T, error)) ResultRunner[T] {
+	return &funcResultRunner[T]{
+		ctx:    ctx,
+		cancel: cancel,
+		f:      f,
+	}
+}
+
+// CTXResultRunner creates a ResultRunner from a context-controlled function
+//
+// You have to call Cancel() to release resources.
+func CTXResultRunner[T any](f func(context.Context) (T, error)) ResultRunner[T] {
+	return CTXResultRunnerWith(context.Background(), f)
+}
+
+// CTXResultRunnerWith creates a ResultRunner from a context-controlled function
+// with predefined context
+//
+// You have to call Cancel() to release resources.
+func CTXResultRunnerWith[T any](ctx context.Context, f func(context.Context) (T, error)) ResultRunner[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	return NewResultRunner(ctx, cancel, func() (T, error) { return f(ctx) })
+}
+
+// AsRunner creates a Runner that runs r and drops the value
+func AsRunner[T any](r ResultRunner[T]) Runner {
+	return NewRunner(r.Context(), r.Cancel, func() (err error) {
+		_, err = r.Run()
+		return
+	})
+}
+
+// Future holds the result of a ResultRunner running in background
+type Future[T any] struct {
+	r    ResultRunner[T]
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// Go runs r in a separated goroutine and returns a Future of its result
+func Go[T any](r ResultRunner[T]) (ret *Future[T]) {
+	ret = &Future[T]{
+		r:    r,
+		done: make(chan struct{}),
+	}
+	spawn("Go", func() {
+		ret.val, ret.err = runResultChild(r)
+		close(ret.done)
+	})
+	return
+}
+
+// Done returns a channel which is closed when the result is ready
+func (f *Future[T]) Done() <-chan struct{} { return f.done }
+
+// Cancel cancels the underlying ResultRunner
+func (f *Future[T]) Cancel() { f.r.Cancel() }
+
+// Await waits the result until ctx is done
+//
+// It returns ctx.Err() if ctx is done before the result is ready. The
+// underlying ResultRunner is not canceled in this case, so you can Await again.
+func (f *Future[T]) Await(ctx context.Context) (ret T, err error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return ret, ctx.Err()
+	}
+}
+
+func cancelAllOf[T any](rs []ResultRunner[T]) context.CancelFunc {
+	return func() {
+		for _, r := range rs {
+			r.Cancel()
+		}
+	}
+}
+
+func funcResultRunnerOf[T any](cancel context.CancelFunc, f func() (T, error)) ResultRunner[T] {
+	ctx, cf := context.WithCancel(context.Background())
+	return NewResultRunner(ctx, func() { cf(); cancel() }, f)
+}
+
+// FirstErrOf is generic version of FirstErr
+//
+// It returns values of rs that have been run.
+func FirstErrOf[T any](rs ...ResultRunner[T]) ResultRunner[[]T] {
+	return funcResultRunnerOf(cancelAllOf(rs), func() (ret []T, err error) {
+		ret = make([]T, 0, len(rs))
+		for _, r := range rs {
+			v, e := r.Run()
+			if e != nil {
+				return ret, e
+			}
+			ret = append(ret, v)
+		}
+
+		return
+	})
+}
+
+// AnyErrOf is generic version of AnyErr
+//
+// Values are ordered as rs, returned value of failed Runner is zero value.
+func AnyErrOf[T any](rs ...ResultRunner[T]) ResultRunner[[]T] {
+	return funcResultRunnerOf(cancelAllOf(rs), func() (ret []T, err error) {
+		ret = make([]T, len(rs))
+		lock := sync.Mutex{}
+		wg := sync.WaitGroup{}
+		wg.Add(len(rs))
+
+		for idx, r := range rs {
+			idx, r := idx, r
+			spawn("AnyErrOf", func() {
+				defer wg.Done()
+				v, e := runResultChild(r)
+				lock.Lock()
+				defer lock.Unlock()
+				if e != nil {
+					if err == nil {
+						err = e
+					}
+					return
+				}
+				ret[idx] = v
+			})
+		}
+
+		wg.Wait()
+		return
+	})
+}
+
+// SkipOf is generic version of Skip
+func SkipOf[T any](rs ...ResultRunner[T]) ResultRunner[T] {
+	c := cancelAllOf(rs)
+	return funcResultRunnerOf(c, func() (T, error) {
+		type result struct {
+			v   T
+			err error
+		}
+		ch := make(chan result, 1)
+
+		for _, r := range rs {
+			r := r
+			spawn("SkipOf", func() {
+				v, err := runResultChild(r)
+				ch <- result{v: v, err: err}
+			})
+		}
+
+		ret := <-ch
+		c()
+		for range rs[1:] {
+			<-ch
+		}
+		return ret.v, ret.err
+	})
+}
+
+// RetryOf is generic version of Retry
+func RetryOf[T any](r ResultRunner[T]) ResultRunner[T] {
+	return NewResultRunner(r.Context(), r.Cancel, func() (ret T, err error) {
+		for {
+			if err = r.Context().Err(); err != nil {
+				return
+			}
+
+			ret, err = runResultChild(r)
+			if _, term := untilSuccess(err); term {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,97 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func value[T any](v T, err error) ResultRunner[T] {
+	return CTXResultRunner(func(context.Context) (T, error) { return v, err })
+}
+
+func TestFuture(t *testing.T) {
+	wait := make(chan struct{})
+	f := Go(CTXResultRunner(func(c context.Context) (int, error) {
+		<-wait
+		return 1, nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := f.Await(ctx); err != context.DeadlineExceeded {
+		t.Fatal("unexpected error:", err)
+	}
+
+	close(wait)
+	v, err := f.Await(context.Background())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if v != 1 {
+		t.Fatal("expected 1, got", v)
+	}
+}
+
+func TestFirstErrOf(t *testing.T) {
+	e := errors.New("")
+	v, err := FirstErrOf(value(1, nil), value(2, e), value(3, nil)).Run()
+	if err != e {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(v) != 1 || v[0] != 1 {
+		t.Fatal("unexpected result:", v)
+	}
+}
+
+func TestAnyErrOf(t *testing.T) {
+	v, err := AnyErrOf(value(1, nil), value(2, nil)).Run()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(v) != 2 || v[0] != 1 || v[1] != 2 {
+		t.Fatal("unexpected result:", v)
+	}
+
+	e := errors.New("")
+	if _, err = AnyErrOf(value(1, nil), value(2, e)).Run(); err != e {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestSkipOf(t *testing.T) {
+	slow := CTXResultRunner(func(c context.Context) (int, error) {
+		<-c.Done()
+		return 0, c.Err()
+	})
+	v, err := SkipOf(slow, value(2, nil)).Run()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if v != 2 {
+		t.Fatal("expected 2, got", v)
+	}
+}
+
+func TestRetryOf(t *testing.T) {
+	cnt := 0
+	r := CTXResultRunner(func(c context.Context) (int, error) {
+		cnt++
+		if cnt < 3 {
+			return 0, errors.New("")
+		}
+		return cnt, nil
+	})
+	v, err := RetryOf(r).Run()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if v != 3 {
+		t.Fatal("expected 3, got", v)
+	}
+}
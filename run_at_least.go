@@ -4,10 +4,7 @@
 
 package ctxroutines
 
-import (
-	"context"
-	"time"
-)
+import "time"
 
 type bypass func(error) bool
 
@@ -22,13 +19,13 @@ type runAtLeast struct {
 }
 
 func (r *runAtLeast) Run() (err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), r.dur)
-	defer cancel()
+	t := clockOf(r).NewTimer(r.dur)
+	defer t.Stop()
 
 	err = r.Runner.Run()
 
 	if !r.bypass(err) {
-		<-ctx.Done()
+		<-t.C()
 	}
 
 	return
@@ -102,8 +102,17 @@ func CTXRunner(f func(context.Context) error) Runner {
 // Typical usage is to wrap a cancelable function for further use (like, passing to
 // Loop()
 //
+// The returned Runner is also a ContextRunner.
+//
 // You have to call Cancel() to release resources.
 func CTXRunnerWith(ctx context.Context, f func(context.Context) error) Runner {
 	ctx, cancel := context.WithCancel(ctx)
-	return NewRunner(ctx, cancel, func() error { return f(ctx) })
+	return &ctxRunner{
+		f: f,
+		funcRunner: funcRunner{
+			ctx:    ctx,
+			cancel: cancel,
+			f:      func() error { return f(ctx) },
+		},
+	}
 }
@@ -0,0 +1,42 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import "time"
+
+// Schedule creates a Runner that runs r at the time matching cron spec in loc
+//
+// See ParseCron for the syntax of spec. loc defaults to time.Local. For
+// example, run r on every weekday at 02:30 in Taipei:
+//
+//     loc, _ := time.LoadLocation("Asia/Taipei")
+//     s, err := Schedule("30 2 * * mon-fri", loc, r, WithOverlap(OverlapQueue))
+//
+// Run() blocks until canceled like Loop, and waits running r to return before
+// returning. Errors returned by r are ignored unless WithErrorHandler is
+// specified.
+//
+// You have to call Cancel() to release resources.
+func Schedule(spec string, loc *time.Location, r Runner, opts ...TickOption) (ret Runner, err error) {
+	c, err := ParseCron(spec)
+	if err != nil {
+		return
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+
+	next := func(t time.Time) time.Time {
+		return c.Next(t.In(loc))
+	}
+	return &tickRunner{
+		plan: func(now time.Time) (time.Time, func(time.Time) time.Time) {
+			return next(now), next
+		},
+		cfg:    newTickConfig(opts),
+		owner:  "Schedule",
+		Runner: r,
+	}, nil
+}
@@ -0,0 +1,126 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"os"
+	"os/signal"
+	"time"
+)
+
+// ShutdownPhase indicates how far the shutdown procedure went
+type ShutdownPhase int
+
+const (
+	// PhaseNone means the Runner returned before receiving any signal
+	PhaseNone ShutdownPhase = iota
+	// PhaseGraceful means the Runner returned after Cancel()
+	PhaseGraceful
+	// PhaseForced means the Runner returned after the force hook
+	PhaseForced
+	// PhaseExit means the Runner did not return, and the exit function is
+	// called
+	PhaseExit
+)
+
+func (p ShutdownPhase) String() string {
+	switch p {
+	case PhaseNone:
+		return "none"
+	case PhaseGraceful:
+		return "graceful"
+	case PhaseForced:
+		return "forced"
+	case PhaseExit:
+		return "exit"
+	}
+	return "unknown"
+}
+
+// ShutdownController runs a Runner and shuts it down in escalating phases
+//
+//   - First signal calls Cancel()
+//   - Second signal, or GracePeriod after first signal, calls Force
+//   - Third signal, or ForcePeriod after Force, calls Exit with ExitCode
+//
+// Zero GracePeriod or ForcePeriod means waiting for next signal forever.
+//
+//     sc := &ShutdownController{
+//         GracePeriod: 10*time.Second,
+//         Force: func() { db.Close() },
+//         ForcePeriod: 5*time.Second,
+//         ExitCode: 1,
+//     }
+//     phase, err := sc.Run(r, os.Interrupt)
+//     log.Printf("stopped in %s phase: %v", phase, err)
+type ShutdownController struct {
+	GracePeriod time.Duration
+	// called when grace period is over, can be nil
+	Force       func()
+	ForcePeriod time.Duration
+	ExitCode    int
+	// defaults to os.Exit
+	Exit func(code int)
+}
+
+func (s *ShutdownController) exit(code int) {
+	if s.Exit == nil {
+		os.Exit(code)
+	}
+	s.Exit(code)
+}
+
+func timerChan(c Clock, dur time.Duration) (ch <-chan time.Time, stop func() bool) {
+	if dur <= 0 {
+		return nil, func() bool { return false }
+	}
+	t := c.NewTimer(dur)
+	return t.C(), t.Stop
+}
+
+// Run runs r until it returns or the process is about to exit, and reports
+// which phase ended it
+//
+// If Exit is overridden and returns, Run returns PhaseExit and nil error
+// without waiting r.
+func (s *ShutdownController) Run(r Runner, sig ...os.Signal) (phase ShutdownPhase, err error) {
+	ch := make(chan os.Signal, 3)
+	signal.Notify(ch, sig...)
+	defer signal.Stop(ch)
+
+	done := make(chan error, 1)
+	spawn("ShutdownController", func() { done <- runChild(r) })
+
+	var timeout <-chan time.Time
+	stop := func() bool { return false }
+	defer func() { stop() }()
+
+	for {
+		select {
+		case err = <-done:
+			return
+		case <-ch:
+		case <-timeout:
+		}
+
+		stop()
+		switch phase {
+		case PhaseNone:
+			phase = PhaseGraceful
+			r.Cancel()
+			timeout, stop = timerChan(clockOf(r), s.GracePeriod)
+		case PhaseGraceful:
+			phase = PhaseForced
+			if s.Force != nil {
+				s.Force()
+			}
+			timeout, stop = timerChan(clockOf(r), s.ForcePeriod)
+		default:
+			phase = PhaseExit
+			s.exit(s.ExitCode)
+			return
+		}
+	}
+}
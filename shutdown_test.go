@@ -0,0 +1,109 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !windows
+// +build !windows
+
+package ctxroutines
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func testShutdown(sc *ShutdownController, signals int, r Runner, start chan int, expect ShutdownPhase) func(*testing.T) {
+	return func(t *testing.T) {
+		type result struct {
+			phase ShutdownPhase
+			err   error
+		}
+		ch := make(chan result, 1)
+		go func() {
+			p, err := sc.Run(r, syscall.SIGUSR1)
+			ch <- result{phase: p, err: err}
+		}()
+		<-start
+
+		for i := 0; i < signals; i++ {
+			syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		select {
+		case res := <-ch:
+			if res.phase != expect {
+				t.Fatalf("expected phase %s, got %s", expect, res.phase)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("not stopped")
+		}
+	}
+}
+
+func TestShutdownController(t *testing.T) {
+	stuck := func(start chan int, forced chan struct{}) Runner {
+		return CTXRunner(func(c context.Context) error {
+			start <- 1
+			<-forced
+			return c.Err()
+		})
+	}
+
+	start := make(chan int, 1)
+	t.Run("graceful", testShutdown(
+		&ShutdownController{},
+		1,
+		CTXRunner(func(c context.Context) error {
+			start <- 1
+			<-c.Done()
+			return c.Err()
+		}),
+		start,
+		PhaseGraceful,
+	))
+
+	forced := make(chan struct{})
+	t.Run("forced-by-timeout", testShutdown(
+		&ShutdownController{
+			GracePeriod: 10 * time.Millisecond,
+			Force:       func() { close(forced) },
+		},
+		1,
+		stuck(start, forced),
+		start,
+		PhaseForced,
+	))
+
+	forced = make(chan struct{})
+	t.Run("forced-by-signal", testShutdown(
+		&ShutdownController{
+			Force: func() { close(forced) },
+		},
+		2,
+		stuck(start, forced),
+		start,
+		PhaseForced,
+	))
+
+	code := -1
+	never := make(chan struct{})
+	defer close(never)
+	t.Run("exit", testShutdown(
+		&ShutdownController{
+			ForcePeriod: 10 * time.Millisecond,
+			ExitCode:    3,
+			Exit:        func(c int) { code = c },
+		},
+		2,
+		stuck(start, never),
+		start,
+		PhaseExit,
+	))
+	if code != 3 {
+		t.Fatal("expected exit code 3, got", code)
+	}
+}
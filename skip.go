@@ -2,15 +2,18 @@ package ctxroutines
 
 // Skip creates a Runner that runs every Runner of rs in separated goroutine,
 // returns first result and cancels others.
+//
+// Use Hedge if you want to start other Runners only when the first one is slow.
 func Skip(rs ...Runner) Runner {
 	c := CancelAll(rs...)
 	return FuncRunner(c, func() error {
 		ch := make(chan error, 1)
 
 		for _, r := range rs {
-			go func(r Runner) {
-				ch <- r.Run()
-			}(r)
+			r := r
+			spawn("Skip", func() {
+				ch <- runChild(r)
+			})
 		}
 
 		ret := <-ch
@@ -0,0 +1,292 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Strategy defines how a Supervisor restarts its children
+type Strategy int
+
+const (
+	// OneForOne restarts only the failed child
+	OneForOne Strategy = iota
+	// OneForAll stops every running child and restarts them all
+	OneForAll
+	// RestForOne stops and restarts the failed child and every child added
+	// after it
+	RestForOne
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case OneForOne:
+		return "one_for_one"
+	case OneForAll:
+		return "one_for_all"
+	case RestForOne:
+		return "rest_for_one"
+	}
+	return "unknown"
+}
+
+// ErrTooManyRestarts is returned by Supervisor when its children are restarted
+// more often than the intensity limit allows
+type ErrTooManyRestarts struct {
+	// the error that triggered last restart
+	Err error
+}
+
+func (e ErrTooManyRestarts) Error() string {
+	return "too many restarts: " + e.Err.Error()
+}
+
+func (e ErrTooManyRestarts) Unwrap() error { return e.Err }
+
+// ErrChildCanceled is returned by Supervisor when a child returns
+// context.Canceled but the Supervisor is not stopping it
+//
+// It usually means the child is canceled by someone else, so restarting it
+// is pointless.
+var ErrChildCanceled = errors.New("child is canceled outside of Supervisor")
+
+// Supervisor is a Runner that runs its children and restarts them if failed
+//
+// A child is considered failed if it returns non-nil error, and finished if it
+// returns nil. Finished children are not restarted. Run() returns
+//
+//   - nil when all children are finished
+//   - context.Canceled when the Supervisor is canceled
+//   - ErrTooManyRestarts when more than maxRestarts restarts happen within
+//     the window, so a parent Supervisor can restart it as a whole
+//   - ErrChildCanceled when a child is canceled by someone else
+//
+// Supervisor is also a ContextRunner, so a parent Supervisor can stop it
+// without canceling it.
+//
+// Children must be added before calling Run(), and Run() must not be called
+// concurrently.
+type Supervisor struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	strategy Strategy
+	max      int
+	window   time.Duration
+	children []func() Runner
+}
+
+// NewSupervisor creates a Supervisor which allows at most maxRestarts restarts
+// within window
+//
+// The window is measured with the Clock of the failed child.
+func NewSupervisor(strategy Strategy, maxRestarts int, window time.Duration) (ret *Supervisor) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Supervisor{
+		ctx:      ctx,
+		cancel:   cancel,
+		strategy: strategy,
+		max:      maxRestarts,
+		window:   window,
+	}
+}
+
+// Add adds Runners as children
+//
+// The same Runner is reused when restarting, so it should be a ContextRunner.
+// Use AddFunc for other Runners if you're using OneForAll or RestForOne.
+func (s *Supervisor) Add(rs ...Runner) {
+	for _, r := range rs {
+		x := r
+		s.children = append(s.children, func() Runner { return x })
+	}
+}
+
+// AddFunc adds Runner factories as children
+//
+// Every (re)start of the child creates a new Runner with f, so it is safe to
+// be stopped by Cancel(). It's also a way to nest Supervisors:
+//
+//     parent.AddFunc(func() Runner {
+//         child := NewSupervisor(OneForOne, 3, time.Minute)
+//         child.Add(r1, r2)
+//         return child
+//     })
+func (s *Supervisor) AddFunc(fs ...func() Runner) {
+	s.children = append(s.children, fs...)
+}
+
+func (s *Supervisor) Context() context.Context { return s.ctx }
+func (s *Supervisor) Cancel()                  { s.cancel() }
+
+type supervisedExit struct {
+	idx int
+	gen int
+	err error
+}
+
+type supervisedChild struct {
+	r       Runner
+	gen     int
+	running bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+type supervision struct {
+	*Supervisor
+	children []*supervisedChild
+	ch       chan supervisedExit
+	live     int
+	restarts []time.Time
+}
+
+func (s *supervision) start(idx int) {
+	c := s.children[idx]
+	c.r = s.Supervisor.children[idx]()
+	c.gen++
+	c.running = true
+	c.done = make(chan struct{})
+	s.live++
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	r, gen, done := c.r, c.gen, c.done
+	spawn("Supervisor", func() {
+		defer cancel()
+		err := runChild(FromRunner(r, func() error {
+			return runCancelable("Supervisor", ctx, r)
+		}))
+		close(done)
+		s.ch <- supervisedExit{idx: idx, gen: gen, err: err}
+	})
+}
+
+func (s *supervision) stop(idx int) {
+	c := s.children[idx]
+	if !c.running {
+		return
+	}
+	c.cancel()
+	<-c.done
+	c.running = false
+}
+
+func (s *supervision) stopAll() {
+	for idx := len(s.children) - 1; idx >= 0; idx-- {
+		s.stop(idx)
+	}
+	for ; s.live > 0; s.live-- {
+		<-s.ch
+	}
+}
+
+func (s *supervision) isRunning() bool {
+	for _, c := range s.children {
+		if c.running {
+			return true
+		}
+	}
+	return false
+}
+
+// allow records a restart at now and reports if it is within the intensity
+// limit
+func (s *supervision) allow(now time.Time) bool {
+	kept := s.restarts[:0]
+	for _, t := range s.restarts {
+		if now.Sub(t) < s.window {
+			kept = append(kept, t)
+		}
+	}
+	s.restarts = append(kept, now)
+	return len(s.restarts) <= s.max
+}
+
+func (s *supervision) restart(idx int) {
+	from, to := idx, idx+1
+	switch s.strategy {
+	case OneForAll:
+		from, to = 0, len(s.children)
+	case RestForOne:
+		to = len(s.children)
+	}
+
+	restart := make([]bool, len(s.children))
+	restart[idx] = true
+	for x := to - 1; x >= from; x-- {
+		if s.children[x].running {
+			restart[x] = true
+			s.stop(x)
+		}
+	}
+	for x := from; x < to; x++ {
+		if restart[x] {
+			s.start(x)
+		}
+	}
+}
+
+// Run starts all children and supervises them
+func (s *Supervisor) Run() error {
+	return s.run(s.ctx)
+}
+
+// RunContext is like Run, but also stops children when ctx is done
+func (s *Supervisor) RunContext(ctx context.Context) error {
+	ctx, cancel := mergeContext("Supervisor", s.ctx, ctx)
+	defer cancel()
+	return s.run(ctx)
+}
+
+func (s *Supervisor) run(ctx context.Context) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	x := &supervision{
+		Supervisor: s,
+		children:   make([]*supervisedChild, len(s.children)),
+		ch:         make(chan supervisedExit),
+	}
+	for idx := range x.children {
+		x.children[idx] = &supervisedChild{}
+		x.start(idx)
+	}
+
+	for x.isRunning() {
+		select {
+		case <-ctx.Done():
+			x.stopAll()
+			return ctx.Err()
+		case e := <-x.ch:
+			x.live--
+			c := x.children[e.idx]
+			if e.gen != c.gen || !c.running {
+				// stopped by us
+				continue
+			}
+			c.running = false
+			if e.err == nil {
+				continue
+			}
+			if errors.Is(e.err, context.Canceled) {
+				x.stopAll()
+				return ErrChildCanceled
+			}
+
+			if !x.allow(clockOf(c.r).Now()) {
+				x.stopAll()
+				return ErrTooManyRestarts{Err: e.err}
+			}
+			x.restart(e.idx)
+		}
+	}
+
+	x.stopAll()
+	return
+}
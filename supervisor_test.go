@@ -0,0 +1,191 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSupervisorOneForOne(t *testing.T) {
+	e := errors.New("")
+	var a, b uint64
+	s := NewSupervisor(OneForOne, 10, time.Minute)
+	s.Add(
+		NoCancelRunner(func() error {
+			if atomic.AddUint64(&a, 1) < 3 {
+				return e
+			}
+			return nil
+		}),
+		NoCancelRunner(func() error {
+			atomic.AddUint64(&b, 1)
+			return nil
+		}),
+	)
+
+	if err := s.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if a != 3 {
+		t.Errorf("expected failed child ran 3 times, got %d", a)
+	}
+	if b != 1 {
+		t.Errorf("expected finished child ran once, got %d", b)
+	}
+}
+
+func testSupervisorRestart(strategy Strategy, expect []uint64) func(*testing.T) {
+	return func(t *testing.T) {
+		e := errors.New("")
+		cnt := make([]uint64, 3)
+		s := NewSupervisor(strategy, 10, time.Minute)
+		block := func(idx int) func() Runner {
+			return func() Runner {
+				return CTXRunner(func(c context.Context) error {
+					atomic.AddUint64(&cnt[idx], 1)
+					<-c.Done()
+					return c.Err()
+				})
+			}
+		}
+		fail := Counter(func(n uint64) error {
+			atomic.AddUint64(&cnt[1], 1)
+			if n == 0 {
+				time.Sleep(10 * time.Millisecond)
+				return e
+			}
+			return nil
+		})
+
+		s.AddFunc(block(0))
+		s.Add(fail)
+		s.AddFunc(block(2))
+		go func() { time.Sleep(50 * time.Millisecond); s.Cancel() }()
+
+		if err := s.Run(); err != context.Canceled {
+			t.Fatal("unexpected error:", err)
+		}
+		for idx, v := range expect {
+			if x := atomic.LoadUint64(&cnt[idx]); x != v {
+				t.Errorf("expected child #%d ran %d times, got %d", idx, v, x)
+			}
+		}
+	}
+}
+
+func TestSupervisorStrategy(t *testing.T) {
+	t.Run("one_for_one", testSupervisorRestart(OneForOne, []uint64{1, 2, 1}))
+	t.Run("one_for_all", testSupervisorRestart(OneForAll, []uint64{2, 2, 2}))
+	t.Run("rest_for_one", testSupervisorRestart(RestForOne, []uint64{1, 2, 2}))
+}
+
+func TestSupervisorIntensity(t *testing.T) {
+	e := errors.New("")
+	var cnt uint64
+	s := NewSupervisor(OneForOne, 3, time.Minute)
+	s.Add(NoCancelRunner(func() error {
+		atomic.AddUint64(&cnt, 1)
+		return e
+	}))
+
+	err := s.Run()
+	var x ErrTooManyRestarts
+	if !errors.As(err, &x) {
+		t.Fatal("unexpected error:", err)
+	}
+	if !errors.Is(err, e) {
+		t.Fatal("expected to wrap child error, got", x.Err)
+	}
+	if cnt != 4 {
+		t.Fatalf("expected ran 4 times, got %d", cnt)
+	}
+}
+
+func TestSupervisorNested(t *testing.T) {
+	e := errors.New("")
+	var cnt uint64
+	parent := NewSupervisor(OneForOne, 1, time.Minute)
+	parent.AddFunc(func() Runner {
+		child := NewSupervisor(OneForOne, 0, time.Minute)
+		child.Add(NoCancelRunner(func() error {
+			atomic.AddUint64(&cnt, 1)
+			return e
+		}))
+		return child
+	})
+
+	err := parent.Run()
+	var x ErrTooManyRestarts
+	if !errors.As(err, &x) {
+		t.Fatal("unexpected error:", err)
+	}
+	if cnt != 2 {
+		t.Fatalf("expected child supervisor restarted once, got %d runs", cnt)
+	}
+}
+
+func TestSupervisorRestartAdded(t *testing.T) {
+	e := errors.New("")
+	var starts, fails uint64
+	started := make(chan struct{})
+	finished := make(chan struct{})
+
+	child := NewSupervisor(OneForOne, 0, time.Minute)
+	child.Add(CTXRunner(func(ctx context.Context) error {
+		atomic.AddUint64(&starts, 1)
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	s := NewSupervisor(OneForAll, 3, time.Minute)
+	s.Add(child, CTXRunner(func(ctx context.Context) error {
+		select {
+		case <-started:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if atomic.AddUint64(&fails, 1) > 2 {
+			close(finished)
+			return nil
+		}
+		return e
+	}))
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run() }()
+	select {
+	case <-finished:
+	case err := <-done:
+		t.Fatal("unexpected error:", err)
+	}
+	s.Cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+	if starts != 3 {
+		t.Fatalf("expected nested Supervisor restarted twice, got %d runs", starts)
+	}
+}
+
+func TestSupervisorChildCanceled(t *testing.T) {
+	var cnt uint64
+	s := NewSupervisor(OneForOne, 3, time.Minute)
+	s.Add(NoCancelRunner(func() error {
+		atomic.AddUint64(&cnt, 1)
+		return context.Canceled
+	}))
+
+	if err := s.Run(); err != ErrChildCanceled {
+		t.Fatal("unexpected error:", err)
+	}
+	if cnt != 1 {
+		t.Fatalf("expected canceled child not to be restarted, got %d runs", cnt)
+	}
+}
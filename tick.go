@@ -0,0 +1,191 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"sync"
+	"time"
+)
+
+// OverlapPolicy decides what to do when it's time to run, but previous run has
+// not finished yet
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips this run
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue runs it after previous runs finished
+	OverlapQueue
+	// OverlapAllow runs it concurrently
+	OverlapAllow
+)
+
+type tickConfig struct {
+	overlap    OverlapPolicy
+	catchUp    bool
+	onError    func(error)
+	fixedDelay bool
+	maxJitter  time.Duration
+	splay      time.Duration
+	align      time.Duration
+	rand       *lockedRand
+}
+
+// jitter returns a random delay added to each tick
+func (c *tickConfig) jitter() time.Duration {
+	return time.Duration(c.rand.Int63n(int64(c.maxJitter)))
+}
+
+// TickOption configures periodic Runners like Schedule and Every
+//
+// Options not supported by the Runner are ignored.
+type TickOption func(*tickConfig)
+
+// WithOverlap sets the OverlapPolicy, defaults to OverlapSkip
+func WithOverlap(p OverlapPolicy) TickOption {
+	return func(c *tickConfig) { c.overlap = p }
+}
+
+// WithJitter delays each run by random duration in [0, d), so runs on several
+// machines are spread out
+func WithJitter(d time.Duration) TickOption {
+	return func(c *tickConfig) { c.maxJitter = d }
+}
+
+// WithCatchUp runs once for every missed tick
+//
+// Ticks might be missed if the process was busy or suspended. They are dropped
+// by default. Missed ticks are queued regardless of OverlapPolicy, so none of
+// them is skipped.
+func WithCatchUp() TickOption {
+	return func(c *tickConfig) { c.catchUp = true }
+}
+
+// WithErrorHandler sets a callback to receive errors returned by the Runner
+//
+// Errors are ignored by default. cb might be called concurrently with
+// OverlapAllow.
+func WithErrorHandler(cb func(error)) TickOption {
+	return func(c *tickConfig) { c.onError = cb }
+}
+
+func newTickConfig(opts []TickOption) (ret *tickConfig) {
+	ret = &tickConfig{
+		onError: func(error) {},
+		rand:    newLockedRand(),
+	}
+	for _, o := range opts {
+		o(ret)
+	}
+	return
+}
+
+// dispatcher runs a Runner according to OverlapPolicy
+type dispatcher struct {
+	r     Runner
+	cfg   *tickConfig
+	owner string
+
+	lock    sync.Mutex
+	running int
+	queued  int
+	wg      sync.WaitGroup
+}
+
+func (d *dispatcher) run() {
+	if err := runChild(d.r); err != nil {
+		d.cfg.onError(err)
+	}
+}
+
+// worker runs queued runs in order, MUST be called with lock held
+func (d *dispatcher) worker() {
+	d.running++
+	d.wg.Add(1)
+	spawn(d.owner, func() {
+		defer d.wg.Done()
+		d.lock.Lock()
+		for d.queued > 0 && !IsCanceled(d.r) {
+			d.queued--
+			d.lock.Unlock()
+			d.run()
+			d.lock.Lock()
+		}
+		d.queued = 0
+		d.running--
+		d.lock.Unlock()
+	})
+}
+
+// dispatch runs the Runner, queue forces OverlapQueue
+func (d *dispatcher) dispatch(queue bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if queue || d.cfg.overlap == OverlapQueue {
+		d.queued++
+		if d.running == 0 {
+			d.worker()
+		}
+		return
+	}
+	if d.cfg.overlap == OverlapSkip && d.running > 0 {
+		return
+	}
+
+	d.running++
+	d.wg.Add(1)
+	spawn(d.owner, func() {
+		defer d.wg.Done()
+		d.run()
+		d.lock.Lock()
+		d.running--
+		// runs queued while running
+		if d.running == 0 && d.queued > 0 {
+			d.worker()
+		}
+		d.lock.Unlock()
+	})
+}
+
+// tickRunner runs Runner at the time computed by plan
+type tickRunner struct {
+	// returns first tick, and a function returns first tick after t
+	//
+	// zero time means there's no more tick
+	plan func(now time.Time) (first time.Time, next func(t time.Time) time.Time)
+	cfg  *tickConfig
+	// combinator creating it, like "Every"
+	owner string
+	Runner
+}
+
+func (t *tickRunner) Run() (err error) {
+	if err = t.Context().Err(); err != nil {
+		return
+	}
+
+	d := &dispatcher{r: t.Runner, cfg: t.cfg, owner: t.owner}
+	defer d.wg.Wait()
+
+	clk := clockOf(t)
+	tick, next := t.plan(clk.Now())
+	missed := false
+	for !tick.IsZero() {
+		if sleep(t.Context(), tick.Add(t.cfg.jitter()).Sub(clk.Now())) {
+			return t.Context().Err()
+		}
+		d.dispatch(missed)
+
+		tick = next(tick)
+		now := clk.Now()
+		missed = t.cfg.catchUp && !tick.After(now)
+		if !t.cfg.catchUp && tick.Before(now) {
+			tick = next(now)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,219 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"time"
+)
+
+// ContextRunner is a Runner that can be stopped by a context bound to single
+// run, without being canceled
+//
+// Combinators stopping runs early, like WithTimeout, Hedge, FirstSuccess, Graph
+// and Supervisor, stop a ContextRunner through RunContext, so it can be run
+// again. Other Runners are canceled when stopped and cannot be run again,
+// except that WithTimeout leaves the timed out run in background until it
+// returns.
+//
+// Runners created by CTXRunner and CTXRunnerWith are ContextRunner. Wrappers
+// like UseClock, Observe, Recover and Named keep it.
+type ContextRunner interface {
+	Runner
+	// RunContext is like Run, but also stops when ctx is done
+	RunContext(ctx context.Context) error
+}
+
+type ctxRunner struct {
+	f func(context.Context) error
+	funcRunner
+}
+
+func (r *ctxRunner) RunContext(ctx context.Context) error {
+	ctx, cancel := mergeContext("CTXRunner", r.ctx, ctx)
+	defer cancel()
+	return r.f(ctx)
+}
+
+// forwardRunner is a ContextRunner which forwards RunContext to f
+type forwardRunner struct {
+	Runner
+	f func(context.Context) error
+}
+
+func (r *forwardRunner) RunContext(ctx context.Context) error { return r.f(ctx) }
+
+// wrapRunner creates a Runner with ctx and cancel, which runs r through wrap
+//
+// The returned Runner is a ContextRunner if r is, and RunContext also runs
+// through wrap.
+func wrapRunner(r Runner, ctx context.Context, cancel context.CancelFunc, wrap func(run func() error) error) Runner {
+	ret := NewRunner(ctx, cancel, func() error { return wrap(r.Run) })
+	x, ok := r.(ContextRunner)
+	if !ok {
+		return ret
+	}
+	return &forwardRunner{
+		Runner: ret,
+		f: func(ctx context.Context) error {
+			return wrap(func() error { return x.RunContext(ctx) })
+		},
+	}
+}
+
+// mergedContext is done when either base or other is done
+//
+// It's derived from other, so contexts derived from it get the error of other,
+// like context.DeadlineExceeded. Values are looked up in base first.
+type mergedContext struct {
+	context.Context
+	base context.Context
+}
+
+func (c *mergedContext) Deadline() (deadline time.Time, ok bool) {
+	deadline, ok = c.Context.Deadline()
+	if d, o := c.base.Deadline(); o && (!ok || d.Before(deadline)) {
+		return d, o
+	}
+	return
+}
+
+func (c *mergedContext) Err() error {
+	err := c.Context.Err()
+	if err == nil {
+		return nil
+	}
+	if e := c.base.Err(); e != nil && err == context.Canceled {
+		return e
+	}
+	return err
+}
+
+func (c *mergedContext) Value(key interface{}) interface{} {
+	if v := c.base.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// mergeContext merges base and other, and the goroutine it may spawn is
+// tracked as owner
+func mergeContext(owner string, base, other context.Context) (ret context.Context, cancel context.CancelFunc) {
+	if other.Done() == nil {
+		return context.WithCancel(base)
+	}
+
+	ctx, cancel := context.WithCancel(other)
+	spawn(owner, func() {
+		select {
+		case <-base.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	})
+	return &mergedContext{Context: ctx, base: base}, cancel
+}
+
+type runTimeoutError struct{}
+
+func (runTimeoutError) Error() string { return "run timeout" }
+func (runTimeoutError) Timeout() bool { return true }
+
+// Is makes errors.Is(ErrRunTimeout, context.DeadlineExceeded) true
+func (runTimeoutError) Is(target error) bool { return target == context.DeadlineExceeded }
+
+// ErrRunTimeout is returned by WithTimeout when a run takes too long
+//
+// errors.Is(ErrRunTimeout, context.DeadlineExceeded) is true.
+var ErrRunTimeout error = runTimeoutError{}
+
+// runContext runs r until it returns or ctx is done
+//
+// If r is not a ContextRunner, it runs r in separated goroutine, and leaves it
+// running after ctx is done.
+func runContext(ctx context.Context, r Runner) (err error) {
+	if x, ok := r.(ContextRunner); ok {
+		return x.RunContext(ctx)
+	}
+	if ctx.Done() == nil {
+		return r.Run()
+	}
+
+	ch := make(chan error, 1)
+	spawn("WithTimeout", func() { ch <- runChild(r) })
+	select {
+	case err = <-ch:
+		return
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runCancelable runs r, and stops it once ctx is done
+//
+// If r is a ContextRunner, it is stopped by the context of the run. Otherwise
+// r.Cancel() is called in a goroutine tracked as owner, so r cannot be run
+// again.
+func runCancelable(owner string, ctx context.Context, r Runner) error {
+	if x, ok := r.(ContextRunner); ok {
+		return x.RunContext(ctx)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	spawn(owner, func() {
+		select {
+		case <-ctx.Done():
+			r.Cancel()
+		case <-stop:
+		}
+	})
+	return r.Run()
+}
+
+type timeoutRunner struct {
+	dur time.Duration
+	Runner
+}
+
+func (r *timeoutRunner) Run() (err error) {
+	return r.RunContext(context.Background())
+}
+
+func (r *timeoutRunner) RunContext(ctx context.Context) (err error) {
+	ctx, cancel := withTimeout(clockOf(r), ctx, r.dur)
+	defer cancel()
+
+	err = runContext(ctx, r.Runner)
+	if err != nil && ctx.Err() == context.DeadlineExceeded && !IsCanceled(r) {
+		err = ErrRunTimeout
+	}
+	return
+}
+
+// WithTimeout creates a ContextRunner that each run of r takes at most dur
+//
+// It returns ErrRunTimeout if timed out, and r can be run again. See
+// ContextRunner for how the timed out run is stopped.
+func WithTimeout(dur time.Duration, r Runner) ContextRunner {
+	return &timeoutRunner{dur: dur, Runner: r}
+}
+
+// RetryWithTimeout is like Retry, but each try of r takes at most timeout
+//
+// See WithTimeout for detail.
+//
+// You have to call Cancel() to release resources.
+func RetryWithTimeout(r Runner, timeout time.Duration) (ret Runner) {
+	return Retry(WithTimeout(timeout, r))
+}
+
+// TryAtMostWithTimeout is like TryAtMost, but each try of f takes at most
+// timeout
+//
+// See WithTimeout for detail.
+func TryAtMostWithTimeout(n uint64, f Runner, timeout time.Duration) (ret Runner) {
+	return TryAtMost(n, WithTimeout(timeout, f))
+}
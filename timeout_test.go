@@ -0,0 +1,181 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	slow := true
+	r := WithTimeout(10*time.Millisecond, CTXRunner(func(c context.Context) error {
+		if !slow {
+			return nil
+		}
+		<-c.Done()
+		return c.Err()
+	}))
+
+	err := r.Run()
+	if err != ErrRunTimeout {
+		t.Fatal("unexpected error:", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expected to be context.DeadlineExceeded")
+	}
+	if IsCanceled(r) {
+		t.Fatal("expected not canceled")
+	}
+
+	slow = false
+	if err = r.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	r.Cancel()
+	slow = true
+	if err = r.Run(); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestWithTimeoutNonContext(t *testing.T) {
+	wait := make(chan struct{})
+	defer close(wait)
+	r := WithTimeout(10*time.Millisecond, NoCancelRunner(func() error {
+		<-wait
+		return nil
+	}))
+
+	if err := r.Run(); err != ErrRunTimeout {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestWithTimeoutWrapped(t *testing.T) {
+	wrappers := map[string]func(Runner) Runner{
+		"Observe": func(r Runner) Runner { return Observe(r, NopObserver{}) },
+		"Recover": Recover,
+		"Named":   func(r Runner) Runner { return Named("x", r) },
+	}
+	for name, wrap := range wrappers {
+		wrap := wrap
+		t.Run(name, func(t *testing.T) {
+			TrackGoroutines(true)
+			defer TrackGoroutines(false)
+
+			f := CTXRunner(func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			defer f.Cancel()
+			r := WithTimeout(10*time.Millisecond, wrap(f))
+			if _, ok := wrap(f).(ContextRunner); !ok {
+				t.Fatal("expected ContextRunner to be kept")
+			}
+			if err := r.Run(); err != ErrRunTimeout {
+				t.Fatal("unexpected error:", err)
+			}
+			waitFor(t, "run to be stopped", func() bool { return len(LiveGoroutines()) == 0 })
+		})
+	}
+}
+
+func TestRunContextValue(t *testing.T) {
+	type key struct{}
+	base := context.WithValue(context.Background(), key{}, 1)
+	r := CTXRunnerWith(base, func(c context.Context) error {
+		if v := c.Value(key{}); v != 1 {
+			t.Error("expected value from base context, got", v)
+		}
+		if _, ok := c.Deadline(); !ok {
+			t.Error("expected deadline of run context")
+		}
+		return nil
+	}).(ContextRunner)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.RunContext(ctx); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestRunContextDerived(t *testing.T) {
+	derived := func(c context.Context) error {
+		ctx, cancel := context.WithCancel(c)
+		defer cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	r := CTXRunner(derived).(ContextRunner)
+	defer r.Cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.RunContext(ctx); err != context.DeadlineExceeded {
+		t.Fatal("expected deadline error in derived context, got", err)
+	}
+
+	r = CTXRunner(derived).(ContextRunner)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		r.Cancel()
+	}()
+	if err := r.RunContext(context.Background()); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// canceled by base, while other has deadline
+	r = CTXRunner(derived).(ContextRunner)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		r.Cancel()
+	}()
+	if err := r.RunContext(ctx); err != context.Canceled {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestTryAtMostWithTimeout(t *testing.T) {
+	cnt := 0
+	r := TryAtMostWithTimeout(5, CTXRunner(func(c context.Context) error {
+		if cnt++; cnt < 3 {
+			<-c.Done()
+			return c.Err()
+		}
+		return nil
+	}), 10*time.Millisecond)
+
+	if err := r.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if cnt != 3 {
+		t.Fatalf("expected ran 3 times, got %d", cnt)
+	}
+}
+
+func TestRetryWithTimeout(t *testing.T) {
+	cnt := 0
+	r := RetryWithTimeout(CTXRunner(func(c context.Context) error {
+		if cnt++; cnt < 3 {
+			<-c.Done()
+			return c.Err()
+		}
+		return nil
+	}), 10*time.Millisecond)
+
+	if err := r.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if cnt != 3 {
+		t.Fatalf("expected ran 3 times, got %d", cnt)
+	}
+}
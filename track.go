@@ -0,0 +1,102 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"context"
+	"runtime/debug"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/raohwork/ctxroutines/internal/hook"
+)
+
+func init() {
+	hook.Spawn = spawn
+	hook.Recovering = isRecovering
+}
+
+var trackGoroutines int32
+
+// TrackGoroutines controls whether goroutines spawned by this package are
+// tracked
+//
+// Tracked goroutines are listed by LiveGoroutines until they return, and are
+// labeled with "ctxroutines" pprof label, whose value is the combinator
+// spawning it, like "Skip". It costs a stack trace for each goroutine, so it's
+// meant for tests and debugging. Only goroutines spawned after enabling are
+// tracked. It is disabled by default.
+func TrackGoroutines(enable bool) {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&trackGoroutines, v)
+}
+
+func isTracking() bool {
+	return atomic.LoadInt32(&trackGoroutines) == 1
+}
+
+// GoroutineInfo describes a tracked goroutine
+type GoroutineInfo struct {
+	// combinator spawning the goroutine, like "Skip"
+	Owner   string
+	Created time.Time
+	// stack trace where the goroutine is spawned
+	Stack []byte
+}
+
+var live = struct {
+	lock sync.Mutex
+	m    map[*GoroutineInfo]struct{}
+}{m: map[*GoroutineInfo]struct{}{}}
+
+// LiveGoroutines returns tracked goroutines which are still running, ordered
+// by creation time
+func LiveGoroutines() (ret []GoroutineInfo) {
+	live.lock.Lock()
+	for g := range live.m {
+		ret = append(ret, *g)
+	}
+	live.lock.Unlock()
+
+	for i := 1; i < len(ret); i++ {
+		for j := i; j > 0 && ret[j].Created.Before(ret[j-1].Created); j-- {
+			ret[j], ret[j-1] = ret[j-1], ret[j]
+		}
+	}
+	return
+}
+
+// spawn runs f in a new goroutine, and tracks it if TrackGoroutines is enabled
+func spawn(owner string, f func()) {
+	if !isTracking() {
+		go f()
+		return
+	}
+
+	g := &GoroutineInfo{
+		Owner:   owner,
+		Created: time.Now(),
+		Stack:   debug.Stack(),
+	}
+	live.lock.Lock()
+	live.m[g] = struct{}{}
+	live.lock.Unlock()
+
+	go func() {
+		defer func() {
+			live.lock.Lock()
+			delete(live.m, g)
+			live.lock.Unlock()
+		}()
+		pprof.Do(context.Background(), pprof.Labels("ctxroutines", owner), func(context.Context) {
+			f()
+		})
+	}()
+}
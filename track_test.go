@@ -0,0 +1,91 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ctxroutines
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestTrackGoroutines(t *testing.T) {
+	TrackGoroutines(true)
+	defer TrackGoroutines(false)
+
+	wait := make(chan struct{})
+	child := NoCancelRunner(func() error {
+		<-wait
+		return nil
+	})
+
+	r := Skip(child, child)
+	done := make(chan error, 1)
+	go func() { done <- r.Run() }()
+
+	waitFor(t, "goroutines to be spawned", func() bool { return len(LiveGoroutines()) == 2 })
+	for _, g := range LiveGoroutines() {
+		if g.Owner != "Skip" || len(g.Stack) == 0 || g.Created.IsZero() {
+			t.Fatalf("unexpected goroutine info: %+v", g)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	pprof.Lookup("goroutine").WriteTo(buf, 1)
+	if !bytes.Contains(buf.Bytes(), []byte(`"ctxroutines":"Skip"`)) {
+		t.Fatal("expected pprof label in goroutine profile")
+	}
+
+	close(wait)
+	<-done
+	waitFor(t, "goroutines to return", func() bool { return len(LiveGoroutines()) == 0 })
+}
+
+func TestTrackGoroutinesOwner(t *testing.T) {
+	TrackGoroutines(true)
+	defer TrackGoroutines(false)
+
+	wait := make(chan struct{})
+	g := NewGraph()
+	g.Add("a", NoCancelRunner(func() error {
+		<-wait
+		return nil
+	}))
+	r, err := g.Build(0, SkipDependents)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- r.Run() }()
+
+	// the node, and the goroutine canceling it
+	waitFor(t, "goroutines to be spawned", func() bool { return len(LiveGoroutines()) == 2 })
+	for _, g := range LiveGoroutines() {
+		if g.Owner != "Graph" {
+			t.Fatalf("unexpected goroutine info: %+v", g)
+		}
+	}
+
+	close(wait)
+	<-done
+	waitFor(t, "goroutines to return", func() bool { return len(LiveGoroutines()) == 0 })
+}
+
+func TestTrackGoroutinesDisabled(t *testing.T) {
+	wait := make(chan struct{})
+	r := Skip(NoCancelRunner(func() error {
+		<-wait
+		return nil
+	}))
+	done := make(chan error, 1)
+	go func() { done <- r.Run() }()
+	defer func() {
+		close(wait)
+		<-done
+	}()
+
+	if l := LiveGoroutines(); len(l) != 0 {
+		t.Fatal("goroutines should not be tracked by default, got", l)
+	}
+}